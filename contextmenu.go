@@ -0,0 +1,77 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	menuItemHeight = 18
+	menuWidth      = 100
+)
+
+// menuItem is one entry of the right-click menu
+type menuItem struct {
+	label  string
+	action func(g *Game)
+}
+
+var contextMenuItems = []menuItem{
+	{"Reset", (*Game).resetRun},
+	{"Undo", (*Game).undoSplit},
+	{"Skip", (*Game).skipSplit},
+	{"Edit Splits", (*Game).openSplitEditor},
+}
+
+// contextMenu is the right-click menu, for running the timer without hotkeys
+type contextMenu struct {
+	open bool
+	x, y int
+}
+
+// updateContextMenu opens the menu on right-click and runs the clicked item
+// on left-click. A left-click anywhere else just closes it.
+func (g *Game) updateContextMenu() {
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		x, y := ebiten.CursorPosition()
+		// Keep the whole menu inside the window
+		g.menu.x = min(x, windowWidth-menuWidth)
+		g.menu.y = min(y, windowHeight-menuItemHeight*len(contextMenuItems))
+		g.menu.open = true
+		return
+	}
+	if !g.menu.open || !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return
+	}
+
+	g.menu.open = false
+	x, y := ebiten.CursorPosition()
+	if x < g.menu.x || x >= g.menu.x+menuWidth || y < g.menu.y {
+		return
+	}
+	if i := (y - g.menu.y) / menuItemHeight; i < len(contextMenuItems) {
+		contextMenuItems[i].action(g)
+	}
+}
+
+// drawContextMenu draws the right-click menu, if open, over everything else
+func (g *Game) drawContextMenu(screen *ebiten.Image) {
+	if !g.menu.open {
+		return
+	}
+	bg := color.RGBA{40, 40, 40, 255}
+	border := color.RGBA{150, 150, 150, 255}
+	white := color.RGBA{255, 255, 255, 255}
+
+	height := menuItemHeight * len(contextMenuItems)
+	vector.DrawFilledRect(screen, float32(g.menu.x), float32(g.menu.y), menuWidth, float32(height), bg, false)
+	vector.StrokeRect(screen, float32(g.menu.x), float32(g.menu.y), menuWidth, float32(height), 1, border, false)
+	for i, item := range contextMenuItems {
+		text.Draw(screen, item.label, basicfont.Face7x13, g.menu.x+8, g.menu.y+i*menuItemHeight+13, white)
+	}
+}
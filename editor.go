@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/nictuku/ooosplits/speedrun"
+	"golang.org/x/image/font/basicfont"
+)
+
+// splitEditor is the state of the split name editor: the names being edited,
+// the selected row and how far the list is scrolled
+type splitEditor struct {
+	nodes    []speedrun.SplitNode
+	selected int
+	offset   int
+	// message is shown when saving fails
+	message string
+}
+
+// openSplitEditor shows the split editor with the current split names. The
+// global hotkeys are suspended while it is open so their keys can be typed.
+func (g *Game) openSplitEditor() {
+	if g.runManager.IsRunning() {
+		g.setEvent("Can't edit splits during a run")
+		return
+	}
+	g.editor = splitEditor{nodes: g.runManager.GetSplitNamesWithDepth()}
+	if len(g.editor.nodes) == 0 {
+		g.editor.nodes = []speedrun.SplitNode{{}}
+	}
+	g.screen = screenEditor
+	g.suspendHotkeys(true)
+}
+
+// closeSplitEditor goes back to the timer and takes the hotkeys again
+func (g *Game) closeSplitEditor() {
+	g.screen = screenMain
+	g.suspendHotkeys(false)
+}
+
+// saveSplitEditor stores the edited names and closes the editor, or shows
+// why they can't be saved
+func (g *Game) saveSplitEditor() {
+	for i, node := range g.editor.nodes {
+		if strings.TrimSpace(node.Name) == "" {
+			g.editor.selected = i
+			g.editor.message = "Split names can't be empty"
+			return
+		}
+	}
+	if err := g.runManager.SetSplitNodes(g.editor.nodes); err != nil {
+		if errors.Is(err, speedrun.ErrRunInProgress) {
+			g.editor.message = "Can't save during a run"
+		} else {
+			g.editor.message = "Error saving splits"
+		}
+		log.Printf("Error saving split names: %v", err)
+		return
+	}
+	g.closeSplitEditor()
+	g.setEvent("Splits saved")
+}
+
+// updateSplitEditor handles typing in the split editor. Up/Down or a click
+// picks a split, Insert adds one below it and Delete removes it, Enter saves
+// and Escape cancels. It reports whether the editor took this frame's input.
+func (g *Game) updateSplitEditor() bool {
+	if g.screen != screenEditor {
+		return false
+	}
+	ed := &g.editor
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.closeSplitEditor()
+		return true
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter):
+		g.saveSplitEditor()
+		return true
+	case repeatingKeyPressed(ebiten.KeyUp) && ed.selected > 0:
+		ed.selected--
+	case repeatingKeyPressed(ebiten.KeyDown) && ed.selected < len(ed.nodes)-1:
+		ed.selected++
+	case inpututil.IsKeyJustPressed(ebiten.KeyInsert):
+		// The new split is at the same level as the selected one
+		node := speedrun.SplitNode{Depth: ed.nodes[ed.selected].Depth}
+		ed.nodes = slices.Insert(ed.nodes, ed.selected+1, node)
+		ed.selected++
+	case inpututil.IsKeyJustPressed(ebiten.KeyDelete) && len(ed.nodes) > 1:
+		ed.nodes = slices.Delete(ed.nodes, ed.selected, ed.selected+1)
+		ed.selected = min(ed.selected, len(ed.nodes)-1)
+	case repeatingKeyPressed(ebiten.KeyBackspace):
+		name := []rune(ed.nodes[ed.selected].Name)
+		if len(name) > 0 {
+			ed.nodes[ed.selected].Name = string(name[:len(name)-1])
+		}
+	}
+
+	if chars := ebiten.AppendInputChars(nil); len(chars) > 0 {
+		ed.nodes[ed.selected].Name += string(chars)
+		ed.message = ""
+	}
+
+	rows := editorRows()
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		// Clicks above the first row select nothing; checked before dividing,
+		// which rounds those up to row 0
+		_, y := ebiten.CursorPosition()
+		if y >= historyTop+4 {
+			row := (y - historyTop - 4) / historyRowHeight
+			if i := ed.offset + row; row < rows && i < len(ed.nodes) {
+				ed.selected = i
+			}
+		}
+	}
+
+	// Keep the selected split in view
+	ed.offset = min(ed.offset, ed.selected)
+	ed.offset = max(ed.offset, ed.selected-rows+1)
+	return true
+}
+
+// editorRows returns how many splits fit on the editor screen, leaving a row
+// for the message
+func editorRows() int {
+	return max(historyRows()-1, 1)
+}
+
+// repeatingKeyPressed reports whether key was just pressed, or has been held
+// long enough to repeat
+func repeatingKeyPressed(key ebiten.Key) bool {
+	const delay, interval = 30, 3
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || (d >= delay && (d-delay)%interval == 0)
+}
+
+// drawSplitEditor draws the split names being edited, with a cursor at the
+// end of the selected one
+func (g *Game) drawSplitEditor(screen *ebiten.Image) {
+	fontFace := basicfont.Face7x13
+	white := color.RGBA{255, 255, 255, 255}
+	gray := color.RGBA{200, 200, 200, 255}
+	red := color.RGBA{255, 0, 0, 255}
+	highlight := color.RGBA{60, 60, 60, 255}
+
+	ed := &g.editor
+	text.Draw(screen, "Edit splits", fontFace, leftPadding, 20, white)
+	help := "Enter to save, Esc to cancel, Insert/Delete to add/remove"
+	text.Draw(screen, shortenStringToFit(help, windowWidth-2*leftPadding, fontFace), fontFace, leftPadding, 36, gray)
+
+	end := min(ed.offset+editorRows(), len(ed.nodes))
+	for row, i := 0, ed.offset; i < end; row, i = row+1, i+1 {
+		y := historyTop + (row+1)*historyRowHeight
+		node := ed.nodes[i]
+		indent := node.Depth * 10
+		name := []rune(node.Name)
+		if i == ed.selected {
+			vector.DrawFilledRect(screen, 0, float32(y-historyRowHeight+4), float32(windowWidth), historyRowHeight,
+				highlight, false)
+			name = append(name, '_')
+		}
+		// Show the end of long names, where the typing happens
+		width := windowWidth - 2*leftPadding - indent
+		for len(name)*7 > width {
+			name = name[1:]
+		}
+		text.Draw(screen, string(name), fontFace, leftPadding+indent, y, white)
+	}
+
+	if ed.message != "" {
+		text.Draw(screen, ed.message, fontFace, leftPadding, historyTop+(editorRows()+1)*historyRowHeight, red)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/nictuku/ooosplits/speedrun"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	historyTop       = 50
+	historyRowHeight = 16
+)
+
+// screenID is which screen the window shows
+type screenID int
+
+const (
+	screenMain screenID = iota
+	screenHistory
+	// screenStats shows the stats of every split, reached from the history
+	screenStats
+	// screenEditor edits the split names, reached from the right-click menu
+	screenEditor
+)
+
+// historyView is the list of past runs, scrolled offset runs down from the
+// newest one, and the split stats, scrolled statsOffset splits down
+type historyView struct {
+	offset int
+	runs   []speedrun.Run
+
+	statsOffset int
+	stats       []speedrun.SplitStats
+}
+
+// historyRows returns how many runs fit on the history screen
+func historyRows() int {
+	return max((windowHeight-historyTop-historyRowHeight)/historyRowHeight, 1)
+}
+
+// updateHistory switches to the history screen and back on the history key,
+// and between the history and the split stats on Tab, and scrolls them with
+// the arrow keys. It reports whether either screen is shown.
+func (g *Game) updateHistory() bool {
+	if g.screen != screenMain && g.runManager.IsRunning() {
+		// A run was started with the hotkeys
+		g.screen = screenMain
+	}
+	if inpututil.IsKeyJustPressed(g.historyKey) {
+		switch {
+		case g.screen != screenMain:
+			g.screen = screenMain
+		case !g.runManager.IsRunning():
+			g.history.offset = 0
+			g.loadHistory()
+			g.screen = screenHistory
+		}
+	}
+	if g.screen == screenMain {
+		return false
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.screen = screenMain
+		return false
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		if g.screen == screenHistory {
+			g.history.statsOffset = 0
+			g.loadSplitStats()
+			g.screen = screenStats
+		} else {
+			g.screen = screenHistory
+		}
+		return true
+	}
+	if g.screen == screenStats {
+		g.scrollSplitStats()
+		return true
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) && g.history.offset > 0 {
+		g.history.offset--
+		g.loadHistory()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) && len(g.history.runs) == historyRows() {
+		g.history.offset++
+		g.loadHistory()
+		if len(g.history.runs) == 0 {
+			// Scrolled past the oldest run
+			g.history.offset--
+			g.loadHistory()
+		}
+	}
+	return true
+}
+
+// loadHistory reads the runs shown at the current scroll position
+func (g *Game) loadHistory() {
+	runs, err := g.runManager.GetRunHistory(historyRows(), g.history.offset)
+	if err != nil {
+		log.Printf("Error loading run history: %v", err)
+		return
+	}
+	g.history.runs = runs
+}
+
+// drawHistory draws the history screen: one row per run with its attempt,
+// date, total time, number of splits and whether it is the PB
+func (g *Game) drawHistory(screen *ebiten.Image) {
+	fontFace := basicfont.Face7x13
+	white := color.RGBA{255, 255, 255, 255}
+	gray := color.RGBA{200, 200, 200, 255}
+	gold := color.RGBA{255, 215, 0, 255}
+
+	text.Draw(screen, "Run history", fontFace, leftPadding, 20, white)
+	help := fmt.Sprintf("Up/Down to scroll, Tab for split stats, %s to go back", g.historyKey)
+	text.Draw(screen, help, fontFace, leftPadding, 36, gray)
+
+	if len(g.history.runs) == 0 {
+		text.Draw(screen, "No runs yet", fontFace, leftPadding, historyTop+historyRowHeight, gray)
+		return
+	}
+
+	numSplits := len(g.runManager.GetSplitNames())
+	for i, run := range g.history.runs {
+		total := run.TimeSince(len(run.Splits) - 1)
+		row := fmt.Sprintf("#%-5d %s %10s %3d/%d", run.AttemptNum, run.StartTime.Format("2006-01-02"),
+			formatDuration(total), len(run.Splits), numSplits)
+		rowColor := gray
+		if run.Completed {
+			rowColor = white
+		}
+		if run.IsPB {
+			row += " PB"
+			rowColor = gold
+		}
+		text.Draw(screen, row, fontFace, leftPadding, historyTop+(i+1)*historyRowHeight, rowColor)
+	}
+}
+
+// loadSplitStats reads the stats of every split
+func (g *Game) loadSplitStats() {
+	g.history.stats = nil
+	for i := range g.runManager.GetSplitNames() {
+		stats, err := g.runManager.GetSplitStatistics(i)
+		if err != nil {
+			log.Printf("Error loading split stats: %v", err)
+			return
+		}
+		g.history.stats = append(g.history.stats, stats)
+	}
+}
+
+// scrollSplitStats scrolls the split stats with the arrow keys
+func (g *Game) scrollSplitStats() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) && g.history.statsOffset > 0 {
+		g.history.statsOffset--
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) && g.history.statsOffset+historyRows() < len(g.history.stats) {
+		g.history.statsOffset++
+	}
+}
+
+// drawSplitStats draws a table with the mean, median, standard deviation,
+// best and worst segment time of each split, and how many runs they are from
+func (g *Game) drawSplitStats(screen *ebiten.Image) {
+	fontFace := basicfont.Face7x13
+	white := color.RGBA{255, 255, 255, 255}
+	gray := color.RGBA{200, 200, 200, 255}
+
+	text.Draw(screen, "Split stats", fontFace, leftPadding, 20, white)
+	help := fmt.Sprintf("Tab for run history, %s to go back", g.historyKey)
+	text.Draw(screen, help, fontFace, leftPadding, 36, gray)
+
+	// Five time columns and the run count share the space right of the names
+	const timeWidth, countWidth = 52, 28
+	nameWidth := windowWidth - 2*leftPadding - 5*timeWidth - countWidth
+	columnX := func(col int) int {
+		return leftPadding + nameWidth + col*timeWidth
+	}
+	for col, label := range []string{"Mean", "Median", "SD", "Best", "Worst", "Runs"} {
+		text.Draw(screen, label, fontFace, columnX(col), historyTop, white)
+	}
+
+	names := g.runManager.GetSplitNames()
+	end := min(g.history.statsOffset+historyRows(), len(g.history.stats))
+	for row, i := 0, g.history.statsOffset; i < end; row, i = row+1, i+1 {
+		y := historyTop + (row+1)*historyRowHeight
+		stats := g.history.stats[i]
+		if i < len(names) {
+			name := shortenStringToFit(names[i], nameWidth-7, fontFace)
+			text.Draw(screen, name, fontFace, leftPadding, y, white)
+		}
+		if stats.SampleCount == 0 {
+			text.Draw(screen, "-", fontFace, columnX(0), y, gray)
+			continue
+		}
+		times := []time.Duration{stats.Mean, stats.Median, stats.StdDev, stats.Min, stats.Max}
+		for col, d := range times {
+			text.Draw(screen, formatDuration(d), fontFace, columnX(col), y, gray)
+		}
+		text.Draw(screen, fmt.Sprint(stats.SampleCount), fontFace, columnX(len(times)), y, gray)
+	}
+}
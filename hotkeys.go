@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nictuku/ooosplits/speedrun"
+)
+
+// HotkeyConfig is the global hotkey of every action, as read from the
+// -config file by LoadHotkeyConfig
+type HotkeyConfig struct {
+	Split HotkeyBinding
+	Reset HotkeyBinding
+	Undo  HotkeyBinding
+	Skip  HotkeyBinding
+	Pause HotkeyBinding
+}
+
+// HotkeyBinding is one hotkey: a Windows virtual-key code and modifiers from
+// alt, ctrl, shift and win
+type HotkeyBinding struct {
+	Key       uint16
+	Modifiers []string
+}
+
+// modifierBits maps the modifier names of a HotkeyBinding to Windows MOD_* bits
+var modifierBits = map[string]uint32{
+	"alt":   1,
+	"ctrl":  2,
+	"shift": 4,
+	"win":   8,
+}
+
+// DefaultHotkeyConfig returns the hotkeys used when nothing else is set
+func DefaultHotkeyConfig() HotkeyConfig {
+	return hotkeyConfigFromKeybinds(speedrun.DefaultKeybinds)
+}
+
+// hotkeyConfigFromKeybinds converts keybinds, e.g. the ones stored in the
+// database, into a HotkeyConfig. Missing actions get their default key.
+func hotkeyConfigFromKeybinds(keybinds map[string]speedrun.Keybind) HotkeyConfig {
+	var config HotkeyConfig
+	for _, action := range speedrun.Actions {
+		kb, ok := keybinds[action]
+		if !ok {
+			kb = speedrun.DefaultKeybinds[action]
+		}
+		binding := config.binding(action)
+		binding.Key = kb.Key
+		for _, name := range []string{"alt", "ctrl", "shift", "win"} {
+			if kb.Modifiers&modifierBits[name] != 0 {
+				binding.Modifiers = append(binding.Modifiers, name)
+			}
+		}
+	}
+	return config
+}
+
+// binding returns the binding of action, or nil if there is no such action
+func (c *HotkeyConfig) binding(action string) *HotkeyBinding {
+	switch action {
+	case speedrun.ActionSplit:
+		return &c.Split
+	case speedrun.ActionReset:
+		return &c.Reset
+	case speedrun.ActionUndo:
+		return &c.Undo
+	case speedrun.ActionSkip:
+		return &c.Skip
+	case speedrun.ActionPause:
+		return &c.Pause
+	}
+	return nil
+}
+
+// keybinds converts the config into keybinds for RunManager.SetKeybinds
+func (c HotkeyConfig) keybinds() (map[string]speedrun.Keybind, error) {
+	keybinds := make(map[string]speedrun.Keybind)
+	for _, action := range speedrun.Actions {
+		binding := c.binding(action)
+		kb := speedrun.Keybind{Key: binding.Key}
+		for _, mod := range binding.Modifiers {
+			bit, ok := modifierBits[strings.ToLower(mod)]
+			if !ok {
+				return nil, fmt.Errorf("invalid modifier %q for %s, want alt, ctrl, shift or win", mod, action)
+			}
+			kb.Modifiers |= bit
+		}
+		keybinds[action] = kb
+	}
+	return keybinds, nil
+}
+
+// LoadHotkeyConfig reads a hotkey config file. It is TOML, or JSON if the
+// name ends in .json. Each action to change has a table with its key, in
+// decimal or 0x hex, and optional modifiers, e.g.
+//
+//	[split]
+//	key = 0x61
+//
+//	[reset]
+//	key = 0x63
+//	modifiers = ["ctrl"]
+//
+// In JSON the same file is {"split": {"key": "0x61"}, "reset": {...}}.
+// Actions left out keep their default key.
+func LoadHotkeyConfig(path string) (HotkeyConfig, error) {
+	config := DefaultHotkeyConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = parseHotkeyJSON(data, &config)
+	} else {
+		err = parseHotkeyTOML(data, &config)
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if _, err := config.keybinds(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// parseHotkeyJSON sets the bindings listed in a JSON config file
+func parseHotkeyJSON(data []byte, config *HotkeyConfig) error {
+	var file map[string]struct {
+		Key       string   `json:"key"`
+		Modifiers []string `json:"modifiers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	for action, b := range file {
+		binding := config.binding(action)
+		if binding == nil {
+			return fmt.Errorf("unknown action %q", action)
+		}
+		key, err := parseKeyCode(b.Key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", action, err)
+		}
+		*binding = HotkeyBinding{Key: key, Modifiers: b.Modifiers}
+	}
+	return nil
+}
+
+// parseHotkeyTOML sets the bindings listed in a TOML config file. It only
+// understands what a hotkey config needs: one table per action holding key,
+// an integer or a string, and modifiers, an array of strings.
+func parseHotkeyTOML(data []byte, config *HotkeyConfig) error {
+	var binding *HotkeyBinding
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripTOMLComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			action, ok := strings.CutSuffix(strings.TrimPrefix(line, "["), "]")
+			action = strings.TrimSpace(action)
+			if binding = config.binding(action); !ok || binding == nil {
+				return fmt.Errorf("line %d: unknown action %q", n+1, action)
+			}
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		if binding == nil {
+			return fmt.Errorf("line %d: %s is outside of an action table", n+1, strings.TrimSpace(name))
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(name) {
+		case "key":
+			if s, err := strconv.Unquote(value); err == nil {
+				value = s
+			}
+			key, err := parseKeyCode(value)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n+1, err)
+			}
+			binding.Key = key
+		case "modifiers":
+			mods, err := parseTOMLStrings(value)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n+1, err)
+			}
+			binding.Modifiers = mods
+		default:
+			return fmt.Errorf("line %d: unknown setting %q, want key or modifiers", n+1, strings.TrimSpace(name))
+		}
+	}
+	return nil
+}
+
+// stripTOMLComment removes a # comment from line, unless the # is in a string
+func stripTOMLComment(line string) string {
+	inString := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			inString = !inString
+		case c == '#' && !inString:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseTOMLStrings parses an array of strings on one line, e.g. ["ctrl", "alt"]
+func parseTOMLStrings(value string) ([]string, error) {
+	inner, ok := strings.CutPrefix(value, "[")
+	if inner, ok = strings.CutSuffix(inner, "]"); !ok {
+		return nil, fmt.Errorf("invalid array %s", value)
+	}
+	var items []string
+	for _, item := range strings.Split(inner, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			// Allows [] and a trailing comma
+			continue
+		}
+		s, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s in array", item)
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
+
+// parseKeyCode parses a virtual-key code in decimal or 0x hex
+func parseKeyCode(s string) (uint16, error) {
+	key, err := strconv.ParseUint(s, 0, 16)
+	if err != nil || key == 0 {
+		return 0, fmt.Errorf("invalid key %q", s)
+	}
+	return uint16(key), nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/nictuku/ooosplits/speedrun"
+)
+
+// writeConfig writes content to a file called name in a temp dir
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHotkeyConfigTOML(t *testing.T) {
+	path := writeConfig(t, "config.toml", `
+# Numpad keys, so the letters stay free
+[split]
+key = 0x61 # Numpad 1
+
+[reset]
+key = "0x63"
+modifiers = ["ctrl", "shift"]
+
+[pause]
+key = 19
+`)
+	config, err := LoadHotkeyConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHotkeyConfig: %v", err)
+	}
+	if config.Split.Key != 0x61 || len(config.Split.Modifiers) != 0 {
+		t.Errorf("split = %+v, want 0x61", config.Split)
+	}
+	if config.Reset.Key != 0x63 || !slices.Equal(config.Reset.Modifiers, []string{"ctrl", "shift"}) {
+		t.Errorf("reset = %+v, want ctrl+shift+0x63", config.Reset)
+	}
+	if config.Pause.Key != 19 {
+		t.Errorf("pause = %+v, want 19", config.Pause)
+	}
+	// Actions left out keep their default
+	if want := DefaultHotkeyConfig().Undo; config.Undo.Key != want.Key {
+		t.Errorf("undo = %+v, want the default %+v", config.Undo, want)
+	}
+
+	keybinds, err := config.keybinds()
+	if err != nil {
+		t.Fatalf("keybinds: %v", err)
+	}
+	if kb := keybinds[speedrun.ActionReset]; kb != (speedrun.Keybind{Key: 0x63, Modifiers: 2 | 4}) {
+		t.Errorf("reset keybind = %+v", kb)
+	}
+}
+
+func TestLoadHotkeyConfigJSON(t *testing.T) {
+	path := writeConfig(t, "hotkeys.json", `{"skip": {"key": "0x62", "modifiers": ["alt"]}}`)
+	config, err := LoadHotkeyConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHotkeyConfig: %v", err)
+	}
+	if config.Skip.Key != 0x62 || !slices.Equal(config.Skip.Modifiers, []string{"alt"}) {
+		t.Errorf("skip = %+v, want alt+0x62", config.Skip)
+	}
+}
+
+func TestLoadHotkeyConfigErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown action":   "[jump]\nkey = 1",
+		"outside a table":  "key = 1",
+		"unknown setting":  "[split]\nbutton = 1",
+		"bad key":          "[split]\nkey = S",
+		"key out of range": "[split]\nkey = 0x10000",
+		"bad modifier":     "[split]\nmodifiers = [\"hyper\"]",
+		"bad array":        "[split]\nmodifiers = ctrl",
+		"no value":         "[split]\nkey",
+	} {
+		if _, err := LoadHotkeyConfig(writeConfig(t, "config.toml", content)); err == nil {
+			t.Errorf("%s: LoadHotkeyConfig succeeded", name)
+		}
+	}
+	if _, err := LoadHotkeyConfig(filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("loading a missing file succeeded")
+	}
+}
+
+func TestDefaultHotkeyConfig(t *testing.T) {
+	keybinds, err := DefaultHotkeyConfig().keybinds()
+	if err != nil {
+		t.Fatalf("keybinds: %v", err)
+	}
+	for _, action := range speedrun.Actions {
+		if keybinds[action] != speedrun.DefaultKeybinds[action] {
+			t.Errorf("%s = %+v, want %+v", action, keybinds[action], speedrun.DefaultKeybinds[action])
+		}
+	}
+
+	// Modifiers survive the trip from stored keybinds and back
+	stored := map[string]speedrun.Keybind{speedrun.ActionSplit: {Key: 0x70, Modifiers: 1 | 8}}
+	keybinds, err = hotkeyConfigFromKeybinds(stored).keybinds()
+	if err != nil {
+		t.Fatalf("keybinds: %v", err)
+	}
+	if keybinds[speedrun.ActionSplit] != stored[speedrun.ActionSplit] {
+		t.Errorf("split = %+v, want %+v", keybinds[speedrun.ActionSplit], stored[speedrun.ActionSplit])
+	}
+}
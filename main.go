@@ -1,15 +1,22 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"image/color"
 	"log"
-	"net/http"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 	"golang.design/x/hotkey"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
@@ -18,18 +25,84 @@ import (
 )
 
 const (
-	windowWidth   = 400
-	windowHeight  = 400
 	eventDuration = time.Second
-	dbPath        = "speedrun.db"
+	// eventFadeDuration is how long the event message takes to fade out
+	eventFadeDuration = 200 * time.Millisecond
+	// pbBannerDuration is how long the NEW PB banner takes to fade out
+	pbBannerDuration = 5 * time.Second
+	defaultDBPath    = "speedrun.db"
+	minWindowSize    = 200
+
+	leftPadding  = 20
+	headerHeight = 100
+	// timerHeight is the space below the split rows kept for the timer block
+	timerHeight = 110
+	// footerHeight is the space at the bottom kept for the event message and
+	// the attribution
+	footerHeight = 43
+
+	minLineSpacing = 12
+	maxLineSpacing = 25
+
+	// longPressDuration is how long the split key must be held to skip
+	longPressDuration = 500 * time.Millisecond
+	// splitFlashDuration is how long a row flashes after it is split
+	splitFlashDuration = 150 * time.Millisecond
+	// minSplitInterval is how soon after a split the split key works again,
+	// so a bouncing key doesn't split twice
+	minSplitInterval = 300 * time.Millisecond
+
+	// The hotkey listener is restarted this many times, this far apart,
+	// before the hotkeys are reported offline
+	maxHotkeyRestarts  = 3
+	hotkeyRestartDelay = time.Second
+
+	// overlayInterval is how often overlay clients are sent the timer
+	overlayInterval = time.Second / 60
+)
+
+// Layout sizes, set by setWindowSize
+var (
+	windowWidth  = 400
+	windowHeight = 400
 
 	nameColumnWidth = 160
 	diffColumnWidth = 50
 	timeColumnWidth = 70
-	lineSpacing     = 20
-	leftPadding     = 20
 )
 
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// setWindowSize sets the window size and scales the columns to its width
+func setWindowSize(width, height int) {
+	windowWidth = width
+	windowHeight = height
+	nameColumnWidth = width * 2 / 5
+	diffColumnWidth = width / 8
+	timeColumnWidth = width * 7 / 40
+}
+
+// windowSizeForAspectRatio maps an -aspect-ratio value to a window size
+func windowSizeForAspectRatio(ratio string) (int, int, error) {
+	switch ratio {
+	case "1:1":
+		return 400, 400, nil
+	case "16:9":
+		return 640, 360, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported aspect ratio %q (want 1:1 or 16:9)", ratio)
+}
+
 func shortenStringToFit(s string, maxWidth int, face font.Face) string {
 	w := font.MeasureString(face, s).Round()
 	if w <= maxWidth {
@@ -47,14 +120,297 @@ func shortenStringToFit(s string, maxWidth int, face font.Face) string {
 	return truncated + ellipsis
 }
 
+// drawScaledText draws s with its baseline at (x, y), scaled by scale
+func drawScaledText(screen *ebiten.Image, s string, face font.Face, x, y int, scale float64, clr color.Color) {
+	if scale == 1 {
+		text.Draw(screen, s, face, x, y, clr)
+		return
+	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(x), float64(y))
+	op.ColorScale.ScaleWithColor(clr)
+	text.DrawWithOptions(screen, s, face, op)
+}
+
+// drawStar draws a five-pointed star of radius r centred on (cx, cy). The
+// basic font has no ★ glyph, so it is drawn with lines.
+func drawStar(screen *ebiten.Image, cx, cy, r float32, clr color.Color) {
+	var xs, ys [5]float32
+	for k := range xs {
+		angle := -math.Pi/2 + float64(k)*2*math.Pi/5
+		xs[k] = cx + r*float32(math.Cos(angle))
+		ys[k] = cy + r*float32(math.Sin(angle))
+	}
+	// Joining every second point gives the star
+	for k := range xs {
+		next := (k + 2) % 5
+		vector.StrokeLine(screen, xs[k], ys[k], xs[next], ys[next], 1.5, clr, true)
+	}
+}
+
+// events is what the hotkey goroutine reports for Draw to show
+type events struct {
+	lastEvent string
+	eventTime time.Time
+	// pbBannerTime is when the last run finished as a new PB
+	pbBannerTime time.Time
+	// splitFlashIndex is the row that was last split, at splitFlashTime
+	splitFlashIndex int
+	splitFlashTime  time.Time
+	// hotkeysOffline is set when the hotkey listener could not be kept running
+	hotkeysOffline bool
+	// hotkeysSuspended is set while the global hotkeys are given back to
+	// the OS, so their keys can be typed
+	hotkeysSuspended bool
+}
+
 type Game struct {
-	lastEvent  string
-	eventTime  time.Time
 	runManager *speedrun.RunManager
-	isFinished bool
+
+	// mu guards events, which the hotkey goroutine writes while Draw reads
+	mu     sync.Mutex
+	events events
+
+	// showCurrentBanner draws the active split name next to the timer
+	showCurrentBanner bool
+	// rightAlignNames lines split names up against the diff columns
+	rightAlignNames bool
+	// focusMode hides completed splits above the current one while running
+	focusMode bool
+	// longPressSkip makes holding the split key skip the current split
+	longPressSkip bool
+	// autoStartAt starts the run automatically at this time, if set
+	autoStartAt time.Time
+	// projection is what the gray time on upcoming splits shows. What the
+	// diff column measures against is the run manager's comparison mode.
+	projection speedrun.ComparisonMode
+	// showCurrentSplitLarge draws the active split name in large text under the splits
+	showCurrentSplitLarge bool
+	// showSegmentTime draws the time spent on the current split under the timer
+	showSegmentTime bool
+	// maxSplitsVisible limits how many split rows are drawn, 0 for no limit
+	maxSplitsVisible int
+	// pbGhost shows the PB's segment time in the comparison column of the
+	// splits not done yet
+	pbGhost bool
+	// showPBDiff, showGoldDiff and showTime pick which columns of the split
+	// table are drawn
+	showPBDiff   bool
+	showGoldDiff bool
+	showTime     bool
+	// showPBSegment adds a column with the PB's segment time of each split,
+	// taking its room from the name column
+	showPBSegment bool
+	// newGoldsThisRun marks the splits of the current run that beat their
+	// gold. It is kept once the run finishes, when the golds are updated
+	// and the diff no longer shows it. Only Draw uses it.
+	newGoldsThisRun map[int]bool
+	// menu is the right-click menu
+	menu contextMenu
+	// editor is the split name editor
+	editor splitEditor
+	// hotkeyChange wakes the hotkey goroutine when events.hotkeysSuspended
+	// changes
+	hotkeyChange chan struct{}
+	// hotkeys are the global hotkeys
+	hotkeys HotkeyConfig
+	// lastSplitTime is when the split key last took effect. Only the hotkey
+	// goroutine uses it.
+	lastSplitTime time.Time
+	// screen is the screen being shown, and historyKey switches to and from
+	// the run history
+	screen     screenID
+	historyKey ebiten.Key
+	history    historyView
+	// segmentDeltas makes the comparison column show how each segment did
+	// against the comparison's segment, instead of the cumulative difference.
+	// deltaKey toggles it.
+	segmentDeltas bool
+	deltaKey      ebiten.Key
+	// comparisonKey switches comparison to the next one that has times
+	comparisonKey ebiten.Key
+
+	// overlay streams the timer state to browser overlays, if enabled.
+	// lastOverlayBroadcast is when it was last sent a state.
+	overlay              *speedrun.OverlayServer
+	lastOverlayBroadcast time.Time
+
+	// currentTime is the run time sampled in Update, shown by the main timer
+	currentTime time.Duration
+	// cachedDisplayTime is the formatted timer for cachedDisplayCentis, so
+	// it is only rebuilt when the shown centiseconds change
+	cachedDisplayTime   string
+	cachedDisplayCentis time.Duration
+}
+
+// visibleSplits returns the range [first, last) of split rows to draw out of
+// numSplits
+func (g *Game) visibleSplits(numSplits int) (first, last int) {
+	focus := g.focusMode && g.runManager.IsRunning()
+	return splitRows(g.runManager.GetCurrentSplit(), numSplits, g.maxSplitsVisible, focus)
+}
+
+// splitRows returns the range [first, last) of split rows to draw out of
+// numSplits, showing at most maxVisible rows if it is positive. With focus
+// set, rows above current are hidden.
+func splitRows(current, numSplits, maxVisible int, focus bool) (first, last int) {
+	if focus {
+		first = current
+	}
+	last = numSplits
+	if maxVisible > 0 && last-first > maxVisible {
+		// Keep the current split in the middle of the window
+		if !focus {
+			first = min(max(current-maxVisible/2, 0), numSplits-maxVisible)
+		}
+		last = first + maxVisible
+	}
+	return first, last
+}
+
+// lineSpacing returns the split row height that fits numSplits rows between
+// the header and the timer
+func lineSpacing(numSplits int) int {
+	spacing := (windowHeight - headerHeight - timerHeight) / max(numSplits, 1)
+	return min(max(spacing, minLineSpacing), maxLineSpacing)
+}
+
+// nameWidth returns the width of the split name column, which gives up room
+// for the PB segment column when that is shown
+func (g *Game) nameWidth() int {
+	if g.showPBSegment {
+		return nameColumnWidth - diffColumnWidth - 10
+	}
+	return nameColumnWidth
+}
+
+// columnPositions returns the X offset of each split table column. Hidden
+// columns take no space, so the ones after them move left.
+func (g *Game) columnPositions() (name, diffPB, gold, pbSegment, time int) {
+	name = leftPadding
+	diffPB = name + g.nameWidth() + 10
+	gold = diffPB
+	if g.showPBDiff {
+		gold += diffColumnWidth + 10
+	}
+	pbSegment = gold
+	if g.showGoldDiff {
+		pbSegment += diffColumnWidth + 10
+	}
+	time = pbSegment
+	if g.showPBSegment {
+		time += diffColumnWidth + 10
+	}
+	return name, diffPB, gold, pbSegment, time
+}
+
+// drawHeader draws the title, category, attempt count and column headers
+// starting at y. It takes up headerHeight pixels and doesn't move with the
+// split rows.
+func (g *Game) drawHeader(screen *ebiten.Image, y int) {
+	fontFace := basicfont.Face7x13
+	white := color.RGBA{255, 255, 255, 255}
+
+	title := g.runManager.GetTitle()
+	category := g.runManager.GetCategory()
+	completedRuns := g.runManager.GetCompletedRuns()
+	attempts := g.runManager.GetAttempts()
+
+	pos := (windowWidth - len(title)*7) / 2
+	text.Draw(screen, title, fontFace, pos, y+20, white)
+	text.Draw(screen, category, fontFace,
+		(windowWidth-len(category)*7)/2, y+40, white)
+	if g.runManager.IsPracticeMode() {
+		orange := color.RGBA{255, 165, 0, 255}
+		text.Draw(screen, "PRACTICE", fontFace, (windowWidth-len("PRACTICE")*7)/2, y+60, orange)
+	} else {
+		attemptText := fmt.Sprintf("%d/%d", completedRuns, attempts)
+		if g.runManager.IsRunning() {
+			attemptText += fmt.Sprintf("  Run #%d", g.runManager.GetCurrentAttemptNumber())
+		}
+		text.Draw(screen, attemptText, fontFace,
+			(windowWidth-len(attemptText)*7)/2, y+60, white)
+	}
+
+	lineXName, lineXDiffPB, lineXGold, lineXPBSegment, lineXTime := g.columnPositions()
+	text.Draw(screen, "Split", fontFace, lineXName, y+80, white)
+	compareHeader := "vs " + g.runManager.ComparisonLabel(g.runManager.GetComparisonMode())
+	if g.segmentDeltas {
+		compareHeader = "Seg " + compareHeader
+	}
+	compareHeader = shortenStringToFit(compareHeader, diffColumnWidth+10, fontFace)
+	if g.showPBDiff {
+		text.Draw(screen, compareHeader, fontFace, lineXDiffPB, y+80, white)
+	}
+	if g.showGoldDiff {
+		text.Draw(screen, "vs Gold", fontFace, lineXGold, y+80, white)
+	}
+	if g.showPBSegment {
+		text.Draw(screen, "PB Seg", fontFace, lineXPBSegment, y+80, white)
+	}
+	if g.showTime {
+		text.Draw(screen, "Time", fontFace, lineXTime, y+80, white)
+	}
+
+	if g.eventSnapshot().hotkeysOffline {
+		red := color.RGBA{255, 0, 0, 255}
+		warning := "HOTKEYS OFFLINE"
+		text.Draw(screen, warning, fontFace, windowWidth-len(warning)*7-leftPadding, y+12, red)
+	}
+}
+
+// nextComparison switches the diff column to the next comparison, skipping
+// those with no times, e.g. the file comparison when no file was given
+func (g *Game) nextComparison() {
+	comparisons := speedrun.Comparisons
+	current := slices.Index(comparisons, g.runManager.GetComparisonMode())
+	for step := 1; step < len(comparisons); step++ {
+		c := comparisons[(current+step)%len(comparisons)]
+		if len(g.runManager.GetComparisonSegments(c)) == 0 {
+			continue
+		}
+		if err := g.runManager.SetComparisonMode(c); err != nil {
+			log.Printf("Error switching comparison: %v", err)
+			return
+		}
+		g.setEvent("vs " + g.runManager.ComparisonLabel(c))
+		return
+	}
+}
+
+// countdownPending reports whether an -auto-start-in countdown is still running
+func (g *Game) countdownPending() bool {
+	return !g.autoStartAt.IsZero() && !g.runManager.IsRunning() && !g.runManager.IsCompleted()
 }
 
 func (g *Game) Update() error {
+	if g.runManager != nil && !g.autoStartAt.IsZero() {
+		if !g.countdownPending() {
+			// Started by hand before the countdown ran out
+			g.autoStartAt = time.Time{}
+		} else if !time.Now().Before(g.autoStartAt) {
+			g.runManager.StartRun()
+			g.autoStartAt = time.Time{}
+			g.setEvent("Started")
+		}
+	}
+	if g.runManager != nil {
+		g.currentTime = g.runManager.GetCurrentTime()
+		if g.overlay != nil && g.overlay.HasClients() && time.Since(g.lastOverlayBroadcast) >= overlayInterval {
+			g.overlay.Broadcast(g.runManager.GetStreamingState().Overlay())
+			g.lastOverlayBroadcast = time.Now()
+		}
+		if !g.updateSplitEditor() && !g.updateHistory() {
+			if inpututil.IsKeyJustPressed(g.deltaKey) {
+				g.segmentDeltas = !g.segmentDeltas
+			}
+			if inpututil.IsKeyJustPressed(g.comparisonKey) {
+				g.nextComparison()
+			}
+			g.updateContextMenu()
+		}
+	}
 	return nil
 }
 
@@ -68,71 +424,137 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	gold := color.RGBA{255, 215, 0, 255}
 	red := color.RGBA{255, 0, 0, 255}
 	gray := color.RGBA{200, 200, 200, 255}
+	ghost := color.RGBA{140, 120, 30, 255}
+
+	if g.runManager == nil {
+		text.Draw(screen, "Timer not initialized", fontFace, leftPadding, 20, red)
+		return
+	}
+
+	if g.screen == screenHistory {
+		g.drawHistory(screen)
+		return
+	}
+	if g.screen == screenStats {
+		g.drawSplitStats(screen)
+		return
+	}
+	if g.screen == screenEditor {
+		g.drawSplitEditor(screen)
+		return
+	}
 
-	title := g.runManager.GetTitle()
-	category := g.runManager.GetCategory()
-	completedRuns := g.runManager.GetCompletedRuns()
-	attempts := g.runManager.GetAttempts()
 	splitNames := g.runManager.GetSplitNames()
+	splitNodes := g.runManager.GetSplitNamesWithDepth()
 	currentSplitIndex := g.runManager.GetCurrentSplit()
 	splits := g.runManager.GetCurrentSplits()
-	pb := g.runManager.GetPersonalBest()
+	golds := g.runManager.GetGoldSegments()
+	compareSegments := g.runManager.GetComparisonSegments(g.runManager.GetComparisonMode())
+	projectionSegments := g.runManager.GetComparisonSegments(g.projection)
+	absoluteTimes := runningTotals(splits)
+	compareAbsoluteTimes := runningTotals(compareSegments)
+	projectedAbsoluteTimes := runningTotals(projectionSegments)
 
-	pos := (windowWidth - len(title)*7) / 2
-	text.Draw(screen, title, fontFace, pos, 20, white)
-	text.Draw(screen, category, fontFace,
-		(windowWidth-len(category)*7)/2, 40, white)
-	attemptText := fmt.Sprintf("%d/%d", completedRuns, attempts)
-	text.Draw(screen, attemptText, fontFace,
-		(windowWidth-len(attemptText)*7)/2, 60, white)
+	ev := g.eventSnapshot()
 
-	lineXName := leftPadding
-	lineXDiffPB := lineXName + nameColumnWidth + 10
-	lineXGold := lineXDiffPB + diffColumnWidth + 10
-	lineXTime := lineXGold + diffColumnWidth + 10
+	// Forget golds of splits that were reset or undone
+	for i := range g.newGoldsThisRun {
+		if i >= len(splits) {
+			delete(g.newGoldsThisRun, i)
+		}
+	}
+
+	g.drawHeader(screen, 0)
 
-	yPos := 80
-	text.Draw(screen, "Split", fontFace, lineXName, yPos, white)
-	text.Draw(screen, "vs PB", fontFace, lineXDiffPB, yPos, white)
-	text.Draw(screen, "vs Gold", fontFace, lineXGold, yPos, white)
-	text.Draw(screen, "Time", fontFace, lineXTime, yPos, white)
+	lineXName, lineXDiffPB, lineXGold, lineXPBSegment, lineXTime := g.columnPositions()
 
-	yPos = 100
+	// Split rows always start below the fixed header
+	yPos := headerHeight
+	firstRow, lastRow := g.visibleSplits(len(splitNames))
+	spacing := lineSpacing(len(splitNames))
+	if g.maxSplitsVisible > 0 {
+		// Size rows for the window, plus the "..." rows marking hidden splits
+		rows := lastRow - firstRow
+		if firstRow > 0 {
+			rows++
+		}
+		if lastRow < len(splitNames) {
+			rows++
+		}
+		spacing = lineSpacing(rows)
+	}
+	if g.maxSplitsVisible > 0 && firstRow > 0 {
+		text.Draw(screen, "...", fontFace, lineXName, yPos, gray)
+		yPos += spacing
+	}
 
 	for i, splitName := range splitNames {
-		displayName := shortenStringToFit(splitName, nameColumnWidth, fontFace)
+		if i < firstRow {
+			continue
+		}
+		if i >= lastRow {
+			text.Draw(screen, "...", fontFace, lineXName, yPos, gray)
+			yPos += spacing
+			break
+		}
+
+		// Flash the row that was just split, fading out quickly
+		if i == ev.splitFlashIndex {
+			if elapsed := time.Since(ev.splitFlashTime); elapsed < splitFlashDuration {
+				a := uint8(255 * (1 - float64(elapsed)/float64(splitFlashDuration)))
+				vector.DrawFilledRect(screen, 0, float32(yPos-spacing+4), float32(windowWidth), float32(spacing),
+					color.RGBA{a, a, a, a}, false)
+			}
+		}
+
+		// Sub-splits are indented and drawn smaller
+		depth := splitNodes[i].Depth
+		nameIndent := depth * 10
+		nameScale := 1 / (1 + 0.2*float64(depth))
+		displayName := shortenStringToFit(splitName, int(float64(g.nameWidth()-nameIndent)/nameScale), fontFace)
+		nameX := lineXName + nameIndent
+		if g.rightAlignNames {
+			nameX = lineXName + g.nameWidth() - int(float64(font.MeasureString(fontFace, displayName).Round())*nameScale)
+		}
 
 		var segmentTime time.Duration
 		var cumulativeTime time.Duration
-		var pbSegmentTime time.Duration
+		var compareSegmentTime time.Duration
 		var goldSegmentTime time.Duration
 		var diffPBStr, diffGoldStr string
 		var diffPBColor, diffGoldColor color.Color = white, white
 
 		isSplitDone := (i < len(splits))
 
-		if pb != nil && i < len(pb.Splits) {
-			pbSegmentTime = pb.Splits[i].Duration
-			goldSegmentTime = pb.Splits[i].BestSegment
+		if i < len(golds) {
+			goldSegmentTime = golds[i]
 		}
-		var pbCumulativeTime time.Duration
 
-		// Always compute the PB cumulative time if available.
-		if pb != nil && i < len(pb.Splits) {
-			for j := 0; j <= i; j++ {
-				pbCumulativeTime += pb.Splits[j].Duration
-			}
+		// The diff column and the gray projection can use different baselines
+		var compareCumulativeTime, projectedCumulativeTime time.Duration
+		if i < len(compareSegments) {
+			compareSegmentTime = compareSegments[i]
+			compareCumulativeTime = compareAbsoluteTimes[i]
+		}
+		if i < len(projectionSegments) {
+			projectedCumulativeTime = projectedAbsoluteTimes[i]
 		}
 
-		if isSplitDone {
+		// A skipped split has no time of its own; cumulative carries forward
+		isSkipped := isSplitDone && splits[i] == 0
+
+		if isSplitDone && !isSkipped {
 			segmentTime = splits[i]
+			cumulativeTime = absoluteTimes[i]
 
-			for j := 0; j <= i; j++ {
-				cumulativeTime += splits[j]
+			hasDiff := compareSegmentTime > 0
+			diffPB := cumulativeTime - compareCumulativeTime
+			if g.segmentDeltas {
+				// The segment after a skip holds two segments' time
+				hasDiff = hasDiff && speedrun.SegmentComparable(splits, i)
+				diffPB = segmentTime - compareSegmentTime
 			}
-
-			if pbSegmentTime > 0 {
-				diffPB := cumulativeTime - pbCumulativeTime
+			if hasDiff {
 				if diffPB < 0 {
 					diffPBStr = fmt.Sprintf("-%s", formatDuration(-diffPB))
 					diffPBColor = green
@@ -146,11 +568,15 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 			}
 
-			if goldSegmentTime > 0 {
+			if goldSegmentTime > 0 && speedrun.SegmentComparable(splits, i) {
 				diffGold := segmentTime - goldSegmentTime
 				if diffGold < 0 {
 					diffGoldStr = fmt.Sprintf("-%s", formatDuration(-diffGold))
 					diffGoldColor = gold
+					if g.newGoldsThisRun == nil {
+						g.newGoldsThisRun = make(map[int]bool)
+					}
+					g.newGoldsThisRun[i] = true
 				} else if diffGold > 0 {
 					diffGoldStr = fmt.Sprintf("+%s", formatDuration(diffGold))
 					diffGoldColor = red
@@ -161,30 +587,218 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			}
 		}
 
-		if i == currentSplitIndex && !g.isFinished && g.runManager.IsRunning() {
-			text.Draw(screen, displayName, fontFace, lineXName, yPos, white)
-			if pbCumulativeTime > 0 {
-				text.Draw(screen, formatDuration(pbCumulativeTime), fontFace, lineXTime, yPos, gray)
+		if i == currentSplitIndex && !g.runManager.IsCompleted() && g.runManager.IsRunning() {
+			drawScaledText(screen, displayName, fontFace, nameX, yPos, nameScale, white)
+
+			// Live diff bar: how much of the comparison segment has been used
+			if compareSegmentTime > 0 {
+				progress := float64(g.runManager.GetCurrentSplitTime()) / float64(compareSegmentTime)
+				if progress > 1 {
+					progress = 1
+				}
+				barColor := green
+				if g.runManager.GetCurrentTime() >= compareCumulativeTime {
+					barColor = red
+				}
+				barWidth := float32(windowWidth-2*leftPadding) * float32(progress)
+				vector.DrawFilledRect(screen, float32(leftPadding), float32(yPos+4), barWidth, 2, barColor, false)
+			}
+			if projectedCumulativeTime > 0 && g.showTime {
+				text.Draw(screen, formatDuration(projectedCumulativeTime), fontFace, lineXTime, yPos, gray)
+			}
+		} else if isSkipped {
+			drawScaledText(screen, displayName, fontFace, nameX, yPos, nameScale, gray)
+			if g.showTime {
+				text.Draw(screen, "-", fontFace, lineXTime, yPos, gray)
 			}
 		} else if isSplitDone {
-			text.Draw(screen, displayName, fontFace, lineXName, yPos, white)
-			text.Draw(screen, diffPBStr, fontFace, lineXDiffPB, yPos, diffPBColor)
-			text.Draw(screen, diffGoldStr, fontFace, lineXGold, yPos, diffGoldColor)
-			text.Draw(screen, formatDuration(cumulativeTime), fontFace, lineXTime, yPos, white)
+			if g.newGoldsThisRun[i] {
+				drawStar(screen, float32(nameX-10), float32(yPos-4), 5, gold)
+			}
+			drawScaledText(screen, displayName, fontFace, nameX, yPos, nameScale, white)
+			if g.showPBDiff {
+				text.Draw(screen, diffPBStr, fontFace, lineXDiffPB, yPos, diffPBColor)
+			}
+			if g.showGoldDiff {
+				text.Draw(screen, diffGoldStr, fontFace, lineXGold, yPos, diffGoldColor)
+			}
+			if g.showTime {
+				text.Draw(screen, formatDuration(cumulativeTime), fontFace, lineXTime, yPos, white)
+			}
 		} else {
-			text.Draw(screen, displayName, fontFace, lineXName, yPos, gray)
-			if pbCumulativeTime > 0 {
-				text.Draw(screen, formatDuration(pbCumulativeTime), fontFace, lineXTime, yPos, gray)
+			drawScaledText(screen, displayName, fontFace, nameX, yPos, nameScale, gray)
+			if projectedCumulativeTime > 0 && g.showTime {
+				text.Draw(screen, formatDuration(projectedCumulativeTime), fontFace, lineXTime, yPos, gray)
+			}
+		}
+
+		// Ghost of the PB's own segment time on the splits still to come
+		if g.pbGhost && g.showPBDiff && !isSplitDone && g.runManager.IsRunning() {
+			if pbSegment := g.runManager.GetPBSegmentDuration(i); pbSegment > 0 {
+				text.Draw(screen, formatDuration(pbSegment), fontFace, lineXDiffPB, yPos, ghost)
+			}
+		}
+		if g.showPBSegment {
+			if pbSegment := g.runManager.GetPBSegmentDuration(i); pbSegment > 0 {
+				text.Draw(screen, formatDuration(pbSegment), fontFace, lineXPBSegment, yPos, gray)
 			}
 		}
 
-		yPos += lineSpacing
+		yPos += spacing
 	}
 
 	var displayTime string
-	displayTime = formatDurationMicro(g.runManager.GetCurrentTime())
+	timerColor := green
+	if g.countdownPending() {
+		displayTime = "-" + formatDurationMicro(time.Until(g.autoStartAt))
+		timerColor = white
+	} else {
+		displayTime = g.displayTime(g.currentTime)
+		if g.runManager.IsPaused() {
+			timerColor = gray
+		}
+	}
+
+	if g.showCurrentSplitLarge && g.runManager.IsRunning() && !g.runManager.IsCompleted() {
+		mediumFontFace := scaledFace(2)
+		currentName := shortenStringToFit(g.runManager.GetCurrentSplitName(), windowWidth-2*leftPadding, mediumFontFace)
+		text.Draw(screen, currentName, mediumFontFace, leftPadding, yPos+5, white)
+		yPos += 5 + mediumFontFace.Descent
+	}
+
+	bigFontFace := scaledFace(3)
+
+	textWidth := font.MeasureString(bigFontFace, displayTime)
+	//x := (windowWidth - textWidth.Round()) / 2
+	// right-align
+	x := windowWidth - textWidth.Round() - leftPadding
+	// Center the timer block, the timer and the lines under it, between the
+	// split table and the footer
+	blockBelow := 38
+	if g.showSegmentTime {
+		blockBelow = 66
+	}
+	ascent := bigFontFace.Ascent
+	timerY := yPos + ascent + (windowHeight-footerHeight-yPos-ascent-blockBelow)/2
+	text.Draw(screen, displayTime, bigFontFace, x, timerY, timerColor)
+
+	// Current split banner, left of the big timer
+	if g.showCurrentBanner && g.runManager.IsRunning() {
+		if name, err := g.runManager.GetSplitNameByIndex(currentSplitIndex); err == nil {
+			bannerText := shortenStringToFit("Current: "+name, x-leftPadding-10, fontFace)
+			text.Draw(screen, bannerText, fontFace, leftPadding, timerY, gold)
+		}
+	}
+
+	// The lines under the timer move down to make room for the segment timer
+	infoY := timerY + 20
+	if g.showSegmentTime {
+		if g.runManager.IsRunning() {
+			mediumFontFace := scaledFace(2)
+			segText := "Seg: " + formatDurationMicro(g.runManager.GetCurrentSplitTime())
+			segWidth := font.MeasureString(mediumFontFace, segText).Round()
+			text.Draw(screen, segText, mediumFontFace, windowWidth-segWidth-leftPadding, timerY+28, white)
+		}
+		infoY = timerY + 48
+	}
+
+	// Add Sum of Best Segments section once there are golds, "-" until
+	// every split has one
+	if slices.ContainsFunc(golds, func(d time.Duration) bool { return d > 0 }) {
+		sobTime := "-"
+		if sumOfBest := g.runManager.GetSumOfBest(); sumOfBest > 0 {
+			sobTime = formatDurationMicro(sumOfBest)
+		}
+		sobText := fmt.Sprintf("Sum of Best: %s", sobTime)
+		sobWidth := font.MeasureString(fontFace, sobText).Round()
+		rightAlignX := windowWidth - sobWidth - leftPadding
+		text.Draw(screen, sobText, fontFace, rightAlignX, infoY, white)
+	}
+
+	// Best possible time, on the line the finish summary uses after the run
+	if g.runManager.IsRunning() {
+		bptText := "BPT: " + formatDurationMicro(g.runManager.GetBestPossibleTime())
+		if missing := g.runManager.MissingGolds(); missing > 0 {
+			bptText += fmt.Sprintf(" (golds missing: %d)", missing)
+		}
+		text.Draw(screen, bptText, fontFace, leftPadding, infoY+18, white)
+	}
+
+	// Finish summary: where the most time went
+	if g.runManager.IsCompleted() {
+		worst, loss := g.runManager.WorstSplit(g.runManager.GetCurrentRun(), g.runManager.GetComparisonMode())
+		if worst >= 0 {
+			name, _ := g.runManager.GetSplitNameByIndex(worst)
+			lossText := fmt.Sprintf("Lost most time on: %s (+%s)", name, formatDuration(loss))
+			lossText = shortenStringToFit(lossText, windowWidth-2*leftPadding, fontFace)
+			text.Draw(screen, lossText, fontFace, leftPadding, infoY+18, red)
+		}
+	}
+
+	attributionText := "OooSplits by OopsKapootz"
+	attributionFontFace := basicfont.Face7x13
+	attributionWidth := font.MeasureString(attributionFontFace, attributionText).Round()
+	attributionX := (windowWidth - attributionWidth) / 2
+	attributionY := windowHeight - 15
+	attributionColor := color.RGBA{150, 150, 150, 255}
+	text.Draw(screen, attributionText, attributionFontFace, attributionX, attributionY, attributionColor)
+
+	// PAUSED overlay over the middle of the window
+	if g.runManager.IsPaused() {
+		pausedFace := scaledFace(4)
+		pausedText := "PAUSED"
+		pausedWidth := font.MeasureString(pausedFace, pausedText).Round()
+		boxY := windowHeight/2 - pausedFace.Ascent - 10
+		vector.DrawFilledRect(screen, 0, float32(boxY), float32(windowWidth), float32(pausedFace.Height+20),
+			color.RGBA{0, 0, 0, 200}, false)
+		text.Draw(screen, pausedText, pausedFace, (windowWidth-pausedWidth)/2, windowHeight/2, white)
+	}
+
+	// NEW PB banner, flashing and fading out
+	if elapsed := time.Since(ev.pbBannerTime); g.runManager.IsCompleted() && elapsed < pbBannerDuration {
+		alpha := 1 - float64(elapsed)/float64(pbBannerDuration)
+		if (elapsed/(250*time.Millisecond))%2 == 1 {
+			alpha /= 2
+		}
+		bannerFace := scaledFace(4)
+		bannerText := "* NEW PB *"
+		bannerWidth := font.MeasureString(bannerFace, bannerText).Round()
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(float64((windowWidth-bannerWidth)/2), float64(windowHeight/2))
+		op.ColorScale.ScaleWithColor(gold)
+		op.ColorScale.ScaleAlpha(float32(alpha))
+		text.DrawWithOptions(screen, bannerText, bannerFace, op)
+	}
+
+	// The event message fades out over the end of eventDuration. It has its
+	// own footer row above the attribution, clear of the header.
+	if elapsed := time.Since(ev.eventTime); elapsed < eventDuration {
+		eventColor := green
+		if remaining := eventDuration - elapsed; remaining < eventFadeDuration {
+			a := uint8(255 * remaining / eventFadeDuration)
+			eventColor = color.RGBA{0, a, 0, a}
+		}
+		text.Draw(screen, ev.lastEvent, fontFace, leftPadding, windowHeight-33, eventColor)
+	}
+
+	g.drawContextMenu(screen)
+}
 
-	scale := 3
+// scaledFaces caches the faces built by scaledFace, by scale
+var scaledFaces = map[int]*basicfont.Face{}
+
+// scaledFace returns basicfont.Face7x13 blown up by an integer factor. Faces
+// are built once per scale, since upscaling the mask is too slow for every frame.
+func scaledFace(scale int) *basicfont.Face {
+	if face, ok := scaledFaces[scale]; ok {
+		return face
+	}
+	face := newScaledFace(scale)
+	scaledFaces[scale] = face
+	return face
+}
+
+func newScaledFace(scale int) *basicfont.Face {
 	originalMask := basicfont.Face7x13.Mask
 	bounds := originalMask.Bounds()
 	newMask := ebiten.NewImage(bounds.Dx()*scale, bounds.Dy()*scale)
@@ -206,7 +820,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		}
 	}
 
-	bigFontFace := &basicfont.Face{
+	return &basicfont.Face{
 		Advance: basicfont.Face7x13.Advance * scale,
 		Width:   basicfont.Face7x13.Width * scale,
 		Height:  basicfont.Face7x13.Height * scale,
@@ -216,40 +830,20 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		Mask:    newMask,
 		Ranges:  basicfont.Face7x13.Ranges,
 	}
+}
 
-	textWidth := font.MeasureString(bigFontFace, displayTime)
-	//x := (windowWidth - textWidth.Round()) / 2
-	// right-align
-	x := windowWidth - textWidth.Round() - leftPadding
-	text.Draw(screen, displayTime, bigFontFace, x, 300, green)
-
-	// Add Sum of Best Segments section
-	if pb != nil {
-		var sumOfBest time.Duration
-		for i := range pb.Splits {
-			if i < len(pb.Splits) && pb.Splits[i].BestSegment > 0 {
-				sumOfBest += pb.Splits[i].BestSegment
-			}
-		}
-
-		sobText := fmt.Sprintf("Sum of Best: %s", formatDurationMicro(sumOfBest))
-		sobWidth := font.MeasureString(fontFace, sobText).Round()
-		rightAlignX := windowWidth - sobWidth - leftPadding
-		text.Draw(screen, sobText, fontFace, rightAlignX, 320, white)
-	}
-
-	attributionText := "OooSplits by OopsKapootz"
-	attributionFontFace := basicfont.Face7x13
-	attributionWidth := font.MeasureString(attributionFontFace, attributionText).Round()
-	attributionX := (windowWidth - attributionWidth) / 2
-	attributionY := windowHeight - 15
-	attributionColor := color.RGBA{150, 150, 150, 255}
-	text.Draw(screen, attributionText, attributionFontFace, attributionX, attributionY, attributionColor)
-
-	if time.Since(g.eventTime) < eventDuration {
-		text.Draw(screen, g.lastEvent, fontFace, 500, 50, green)
+// runningTotals returns the time at the end of each of segments, so Draw
+// doesn't add them up again for every row
+func runningTotals(segments []time.Duration) []time.Duration {
+	totals := make([]time.Duration, len(segments))
+	var total time.Duration
+	for i, segment := range segments {
+		total += segment
+		totals[i] = total
 	}
+	return totals
 }
+
 func formatDuration(d time.Duration) string {
 	minutes := int(d.Minutes())
 	seconds := int(d.Seconds()) % 60
@@ -273,113 +867,584 @@ func formatDurationMicro(d time.Duration) string {
 	return fmt.Sprintf("%02d:%02d.%02d", minutes, seconds, centiseconds)
 }
 
+// displayTime returns the formatted main timer for d, reusing the last string
+// while the shown value hasn't changed
+func (g *Game) displayTime(d time.Duration) string {
+	centis := d.Truncate(10 * time.Millisecond)
+	if g.cachedDisplayTime == "" || centis != g.cachedDisplayCentis {
+		g.cachedDisplayTime = formatDurationMicro(centis)
+		g.cachedDisplayCentis = centis
+	}
+	return g.cachedDisplayTime
+}
+
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	if outsideWidth < minWindowSize || outsideHeight < minWindowSize {
+		return minWindowSize, minWindowSize
+	}
 	return windowWidth, windowHeight
 }
 
 func main() {
-	var importFile string
-	flag.StringVar(&importFile, "import", "", "Import configuration from JSON file")
+	var importFiles stringList
+	var configFile string
+	var importSplitsIO string
+	var exportSplitsIO string
+	var exportFile, exportLSS string
+	var dbPath string
+	var noCreate bool
+	var debug bool
+	var includeArchived bool
+	var practice bool
+	var seed bool
+	var showCurrentBanner bool
+	var showCurrentSplitLarge bool
+	var showSegmentTime bool
+	var historyKey ebiten.Key
+	var deltaKey ebiten.Key
+	var maxSplitsVisible int
+	var showPBDiff, showGoldDiff, showTime bool
+	var pbGhost bool
+	var showPBSegment bool
+	var autoStart bool
+	var focusMode bool
+	var rightAlignNames bool
+	var longPressSkip bool
+	var textFile string
+	var overlayAddr string
+	var overlayOrigins stringList
+	var compareName, projectionName string
+	var aspectRatio string
+	var autoStartIn time.Duration
+	var compareFile, compareLabel string
+	var compareRun int
+	var comparisonKey ebiten.Key
+	var textFileSplit bool
+	flag.Var(&importFiles, "import", "Import configuration from a JSON or LiveSplit .lss file (repeat to import several, in order)")
+	flag.StringVar(&exportFile, "export", "", "Export the config, golds and PB to this JSON file (same format as -import), then exit")
+	flag.StringVar(&exportLSS, "export-lss", "", "Export the config, golds and PB to this LiveSplit .lss file, then exit")
+	flag.StringVar(&exportSplitsIO, "export-splitsio", "", "Export all run history to this file in Splits.io Exchange Format, then exit")
+	flag.StringVar(&importSplitsIO, "import-splitsio", "", "Import the fastest splits.io run of game/category")
+	flag.StringVar(&dbPath, "db", defaultDBPath, "Path to the SQLite database")
+	flag.StringVar(&configFile, "config", "", "TOML file, or JSON if it ends in .json, with hotkeys to store in the database before starting")
+	flag.BoolVar(&seed, "seed", false, "Fill an empty database with demo runs, then start")
+	flag.BoolVar(&noCreate, "no-create", false, "Fail instead of creating the database if it does not exist")
+	flag.BoolVar(&debug, "debug", false, "Log warnings about misuse of the database that is otherwise ignored")
+	flag.BoolVar(&includeArchived, "include-archived", false, "Count archived runs towards golds and stats")
+	flag.BoolVar(&showCurrentBanner, "current-banner", false, "Show the current split name next to the timer")
+	flag.StringVar(&textFile, "text-file", "", "Continuously write the timer to this file (for OBS text sources)")
+	flag.BoolVar(&textFileSplit, "text-file-split", false, "Also write the current split and PB delta to -text-file")
+	flag.DurationVar(&autoStartIn, "auto-start-in", 0, "Count down and start the run automatically after this long (e.g. 10s)")
+	flag.StringVar(&aspectRatio, "aspect-ratio", "1:1", "Window aspect ratio: 1:1 (400x400) or 16:9 (640x360)")
+	flag.StringVar(&compareName, "compare", "pb", "What the diff column compares against: pb, best, average, median, file or run")
+	flag.StringVar(&projectionName, "projection", "pb", "What the gray upcoming split times show: pb, best, average, median, file or run")
+	flag.IntVar(&compareRun, "compare-run", 0, "ID of the run to use as the \"run\" comparison")
+	flag.TextVar(&comparisonKey, "comparison-key", ebiten.KeyC, "Key that switches the diff column to the next comparison")
+	flag.StringVar(&compareFile, "compare-file", "", "JSON splits file to use as the \"file\" comparison, without touching the DB")
+	flag.StringVar(&compareLabel, "compare-label", "File", "Header label for the -compare-file comparison")
+	flag.BoolVar(&rightAlignNames, "right-align-names", false, "Right-align split names against the time columns")
+	flag.BoolVar(&practice, "practice", false, "Practice mode: runs are compared against the PB but never saved")
+	flag.BoolVar(&showPBDiff, "show-pb-diff", true, "Show the column comparing each split to the comparison (PB by default)")
+	flag.BoolVar(&showGoldDiff, "show-gold-diff", true, "Show the column comparing each segment to its gold")
+	flag.BoolVar(&showTime, "show-time", true, "Show the column with the run time at each split")
+	flag.BoolVar(&showPBSegment, "show-pb-seg", true, "Show a column with the PB's segment time of each split, before the Time column")
+	flag.BoolVar(&pbGhost, "pb-ghost", false, "While running, show the PB's segment time on each split still to come")
+	flag.IntVar(&maxSplitsVisible, "max-splits-visible", 0, "Show at most this many split rows, centered on the current split (0 shows all)")
+	flag.BoolVar(&focusMode, "focus", false, "Hide completed splits while a run is in progress")
+	flag.BoolVar(&longPressSkip, "long-press-skip", false, "Hold the split key for half a second to skip the current split")
+	flag.BoolVar(&autoStart, "auto-start", false, "Start a fresh run when pressing split after finishing, without resetting first")
+	flag.BoolVar(&showCurrentSplitLarge, "show-current-split-large", false, "Show the current split name in large text below the splits")
+	flag.BoolVar(&showSegmentTime, "segment-timer", false, "Show the time spent on the current split under the main timer")
+	flag.TextVar(&historyKey, "history-key", ebiten.KeyH, "Key that opens the run history while no run is in progress")
+	flag.StringVar(&overlayAddr, "overlay-addr", "", "Address for a WebSocket server streaming the timer to overlays, e.g. localhost:6060 (off by default)")
+	flag.Var(&overlayOrigins, "overlay-origin", "Web page origin, e.g. https://overlay.example, allowed to read the timer besides pages served from this machine (repeat for several)")
+	flag.TextVar(&deltaKey, "delta-key", ebiten.KeyD, "Key that switches the comparison column between cumulative and per-segment differences")
 	flag.Parse()
 
-	log.Println("Starting pprof server on localhost:6060")
-	go func() {
-		log.Println(http.ListenAndServe("localhost:6060", nil))
-	}()
+	width, height, err := windowSizeForAspectRatio(aspectRatio)
+	if err != nil {
+		log.Fatalf("Invalid -aspect-ratio: %v", err)
+	}
+	setWindowSize(width, height)
 
-	runManager, err := speedrun.NewRunManager(dbPath)
+	comparison, err := speedrun.ParseComparison(compareName)
+	if err != nil {
+		log.Fatalf("Invalid -compare: %v", err)
+	}
+	projection, err := speedrun.ParseComparison(projectionName)
+	if err != nil {
+		log.Fatalf("Invalid -projection: %v", err)
+	}
+	if maxSplitsVisible < 0 {
+		log.Fatalf("Invalid -max-splits-visible: %d is negative", maxSplitsVisible)
+	}
+	if practice {
+		// Practice always paces against the PB
+		comparison = speedrun.ComparisonPB
+	}
+
+	opts := speedrun.DefaultNewRunManagerOptions
+	opts.NoCreate = noCreate
+	opts.Debug = debug
+	runManager, err := speedrun.NewRunManagerWithOptions(dbPath, opts)
+	if errors.Is(err, speedrun.ErrDatabaseNotFound) {
+		log.Fatalf("Database %s does not exist (-no-create is set)", dbPath)
+	}
 	if err != nil {
 		log.Fatalf("Failed to initialize run manager: %v", err)
 	}
 	defer runManager.Close()
+	runManager.SetAutoStart(autoStart)
+	runManager.SetPracticeMode(practice)
+	if includeArchived {
+		if err := runManager.SetIncludeArchived(true); err != nil {
+			log.Fatalf("Failed to include archived runs: %v", err)
+		}
+	}
 
-	if importFile != "" {
-		log.Printf("Importing configuration from %s", importFile)
-		if err := runManager.ImportFromJSON(importFile); err != nil {
+	if seed {
+		log.Printf("Seeding %s with demo data", dbPath)
+		if err := runManager.SeedDemoData(); err != nil {
+			log.Fatalf("Failed to seed database: %v", err)
+		}
+	}
+
+	if len(importFiles) > 0 {
+		log.Printf("Importing configuration from %s", importFiles)
+		if err := runManager.ImportFiles(importFiles...); err != nil {
 			log.Fatalf("Failed to import configuration: %v", err)
 		}
 		log.Printf("Successfully imported configuration")
 	}
 
+	if importSplitsIO != "" {
+		gameID, categoryID, ok := strings.Cut(importSplitsIO, "/")
+		if !ok {
+			log.Fatalf("Invalid -import-splitsio %q, want game/category", importSplitsIO)
+		}
+		log.Printf("Importing fastest run of %s from splits.io", importSplitsIO)
+		if err := runManager.ImportFromSplitsIO(gameID, categoryID); err != nil {
+			log.Fatalf("Failed to import from splits.io: %v", err)
+		}
+		log.Printf("Successfully imported from splits.io")
+	}
+
+	if exportFile != "" {
+		f, err := os.Create(exportFile)
+		if err != nil {
+			log.Fatalf("Failed to create export file: %v", err)
+		}
+		if err := runManager.ExportToJSON(f); err != nil {
+			log.Fatalf("Failed to export to JSON: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("Failed to write export file: %v", err)
+		}
+		log.Printf("Exported splits and PB to %s", exportFile)
+		return
+	}
+
+	if exportLSS != "" {
+		f, err := os.Create(exportLSS)
+		if err != nil {
+			log.Fatalf("Failed to create export file: %v", err)
+		}
+		if err := runManager.ExportToLSS(f); err != nil {
+			log.Fatalf("Failed to export to LSS: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("Failed to write export file: %v", err)
+		}
+		log.Printf("Exported splits and PB to %s", exportLSS)
+		return
+	}
+
+	if exportSplitsIO != "" {
+		if err := runManager.ExportToSplitsIO(exportSplitsIO); err != nil {
+			log.Fatalf("Failed to export to splits.io format: %v", err)
+		}
+		log.Printf("Exported run history to %s", exportSplitsIO)
+		return
+	}
+
+	if compareFile != "" {
+		if err := runManager.LoadComparisonFromJSON(compareFile, compareLabel); err != nil {
+			log.Fatalf("Failed to load comparison file: %v", err)
+		}
+	}
+
+	if compareRun != 0 {
+		if err := runManager.SetComparisonMode(speedrun.ComparisonRunID, compareRun); err != nil {
+			log.Fatalf("Failed to load comparison run: %v", err)
+		}
+	}
+	if err := runManager.SetComparisonMode(comparison); err != nil {
+		log.Fatalf("Invalid -compare: %v", err)
+	}
+
+	// The -config file is stored in the database, which holds the hotkeys
+	// otherwise
+	var hotkeys HotkeyConfig
+	if configFile != "" {
+		hotkeys, err = LoadHotkeyConfig(configFile)
+		if err != nil {
+			log.Fatalf("Failed to load hotkey config: %v", err)
+		}
+		keybinds, err := hotkeys.keybinds()
+		if err != nil {
+			log.Fatalf("Invalid hotkey config: %v", err)
+		}
+		if err := runManager.SetKeybinds(keybinds); err != nil {
+			log.Fatalf("Failed to update hotkeys: %v", err)
+		}
+	} else {
+		keybinds, err := runManager.GetKeybinds()
+		if err != nil {
+			log.Fatalf("Failed to load keybinds: %v", err)
+		}
+		hotkeys = hotkeyConfigFromKeybinds(keybinds)
+	}
+
 	game := &Game{
-		runManager: runManager,
-		isFinished: false,
+		runManager:            runManager,
+		showCurrentBanner:     showCurrentBanner,
+		focusMode:             focusMode,
+		rightAlignNames:       rightAlignNames,
+		longPressSkip:         longPressSkip,
+		projection:            projection,
+		showCurrentSplitLarge: showCurrentSplitLarge,
+		showSegmentTime:       showSegmentTime,
+		maxSplitsVisible:      maxSplitsVisible,
+		showPBDiff:            showPBDiff,
+		showGoldDiff:          showGoldDiff,
+		showTime:              showTime,
+		showPBSegment:         showPBSegment,
+		pbGhost:               pbGhost,
+		hotkeys:               hotkeys,
+		historyKey:            historyKey,
+		deltaKey:              deltaKey,
+		comparisonKey:         comparisonKey,
+		hotkeyChange:          make(chan struct{}, 1),
+	}
+
+	if autoStartIn > 0 {
+		game.autoStartAt = time.Now().Add(autoStartIn)
+	}
+
+	if overlayAddr != "" {
+		game.overlay = speedrun.NewOverlayServer(overlayAddr, runManager, overlayOrigins...)
+		defer game.overlay.Close()
+		log.Printf("Starting overlay server on %s", overlayAddr)
+		go func() {
+			if err := game.overlay.ListenAndServe(); err != nil {
+				log.Printf("Overlay server stopped: %v", err)
+			}
+		}()
 	}
 
 	ebiten.SetWindowSize(windowWidth, windowHeight)
+	ebiten.SetWindowSizeLimits(minWindowSize, minWindowSize, -1, -1)
 	ebiten.SetWindowTitle("Speedrun Timer")
 	ebiten.SetTPS(120)
 
-	go registerHotkeys(game)
+	if textFile != "" {
+		go runTextExport(runManager, textFile, textFileSplit)
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go superviseHotkeys(game, quit, done)
 
-	if err := ebiten.RunGame(game); err != nil {
+	err = ebiten.RunGame(game)
+
+	// Give the OS hotkeys back before exiting
+	close(quit)
+	<-done
+
+	// ebiten.Termination means the game asked to quit, which is a normal exit
+	if err != nil && !errors.Is(err, ebiten.Termination) {
 		log.Fatal(err)
 	}
 }
 
-func registerHotkeys(g *Game) {
-	hkSplit := hotkey.New([]hotkey.Modifier{}, hotkey.Key(0x53))
-	hkReset := hotkey.New([]hotkey.Modifier{}, hotkey.Key(0x55))
-	hkUndo := hotkey.New([]hotkey.Modifier{}, hotkey.Key(0x5B))
+// superviseHotkeys runs registerHotkeys until quit is closed, restarting it if
+// it stops early. After too many restarts the hotkeys are reported offline.
+// done is closed once the hotkeys have been given back.
+func superviseHotkeys(g *Game, quit <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	for restarts := 0; ; restarts++ {
+		err := runHotkeys(g, quit)
+		if errors.Is(err, errHotkeysSuspended) {
+			// Not a failure: wait until they are wanted back
+			if !g.waitHotkeysResumed(quit) {
+				return
+			}
+			restarts--
+			continue
+		}
+		if err != nil {
+			log.Printf("Hotkey listener stopped: %v", err)
+		}
+		select {
+		case <-quit:
+			return
+		default:
+		}
+
+		if restarts == maxHotkeyRestarts {
+			log.Printf("Hotkey listener failed %d times, giving up", restarts+1)
+			g.mu.Lock()
+			g.events.hotkeysOffline = true
+			g.mu.Unlock()
+			return
+		}
+		select {
+		case <-quit:
+			return
+		case <-time.After(hotkeyRestartDelay):
+		}
+	}
+}
+
+// errHotkeysSuspended is returned by registerHotkeys when it gave the hotkeys
+// back because suspendHotkeys asked it to
+var errHotkeysSuspended = errors.New("hotkeys suspended")
+
+// suspendHotkeys gives the global hotkeys back to the OS, or takes them again,
+// e.g. so their keys can be typed in the split editor
+func (g *Game) suspendHotkeys(suspended bool) {
+	g.mu.Lock()
+	g.events.hotkeysSuspended = suspended
+	g.mu.Unlock()
+	select {
+	case g.hotkeyChange <- struct{}{}:
+	default:
+	}
+}
+
+// hotkeysSuspended reports whether suspendHotkeys gave the hotkeys back
+func (g *Game) hotkeysSuspended() bool {
+	return g.eventSnapshot().hotkeysSuspended
+}
 
-	if err := hkUndo.Register(); err != nil {
-		log.Printf("Failed to register Undo hotkey: %v", err)
+// waitHotkeysResumed waits until the hotkeys are no longer suspended. It
+// returns false if quit was closed first.
+func (g *Game) waitHotkeysResumed(quit <-chan struct{}) bool {
+	for g.hotkeysSuspended() {
+		select {
+		case <-quit:
+			return false
+		case <-g.hotkeyChange:
+		}
 	}
-	if err := hkReset.Register(); err != nil {
-		log.Printf("Failed to register Reset hotkey: %v", err)
+	return true
+}
+
+// runHotkeys calls registerHotkeys, turning a panic into an error
+func runHotkeys(g *Game, quit <-chan struct{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return registerHotkeys(g, g.hotkeys, quit)
+}
+
+// registerHotkeys listens for the global hotkeys in config until quit is
+// closed, then unregisters them. It returns an error if any of them can't be
+// registered, e.g. because another program already uses the key, and
+// errHotkeysSuspended once suspendHotkeys asks for them back.
+func registerHotkeys(g *Game, config HotkeyConfig, quit <-chan struct{}) error {
+	if g.hotkeysSuspended() {
+		return errHotkeysSuspended
 	}
-	if err := hkSplit.Register(); err != nil {
-		log.Printf("Failed to register Split hotkey: %v", err)
+	keybinds, err := config.keybinds()
+	if err != nil {
+		return err
 	}
+	hotkeys := make(map[string]*hotkey.Hotkey)
+	defer func() {
+		for action, hk := range hotkeys {
+			if err := hk.Unregister(); err != nil {
+				log.Printf("Failed to unregister %s hotkey: %v", action, err)
+			}
+		}
+	}()
+	for _, action := range speedrun.Actions {
+		kb := keybinds[action]
+		var mods []hotkey.Modifier
+		if kb.Modifiers != 0 {
+			mods = append(mods, hotkey.Modifier(kb.Modifiers))
+		}
+		hk := hotkey.New(mods, hotkey.Key(kb.Key))
+		if err := hk.Register(); err != nil {
+			return fmt.Errorf("failed to register %s hotkey: %w", action, err)
+		}
+		hotkeys[action] = hk
+	}
+	hkSplit := hotkeys[speedrun.ActionSplit]
+	hkReset := hotkeys[speedrun.ActionReset]
+	hkUndo := hotkeys[speedrun.ActionUndo]
+	hkSkip := hotkeys[speedrun.ActionSkip]
+	hkPause := hotkeys[speedrun.ActionPause]
 
+	// longPress fires when the split key has been held long enough to skip.
+	// It is nil when no press is pending.
+	var longPress <-chan time.Time
 	for {
 		select {
+		case <-quit:
+			return nil
+
+		case <-g.hotkeyChange:
+			if g.hotkeysSuspended() {
+				return errHotkeysSuspended
+			}
+
 		case <-hkSplit.Keydown():
-			if g.isFinished {
+			// With long-press skip, a mid-run split waits for the key to
+			// be released, since holding it turns the press into a skip.
+			// Starting and finishing can't be skipped, so they stay instant.
+			if g.longPressSkip && g.runManager.IsRunning() && !g.runManager.IsOnLastSplit() {
+				if longPress == nil {
+					longPress = time.After(longPressDuration)
+				}
 				continue
 			}
-			if !g.runManager.IsRunning() {
-				g.runManager.StartRun()
-				g.lastEvent = "Started"
-			} else {
-				isFinished, err := g.runManager.Split()
-				if err != nil {
-					log.Printf("Error recording split: %v", err)
-				}
-				if isFinished {
-					g.isFinished = true
-					g.lastEvent = "Finished"
-				} else {
-					g.lastEvent = "Split"
-				}
+			g.handleSplitKey()
+
+		case <-hkSplit.Keyup():
+			if longPress != nil {
+				longPress = nil
+				g.handleSplitKey()
 			}
-			g.eventTime = time.Now()
-			log.Println("Split triggered")
+
+		case <-longPress:
+			longPress = nil
+			g.skipSplit()
 
 		case <-hkUndo.Keydown():
-			if !g.isFinished && g.runManager.IsRunning() {
-				if err := g.runManager.UndoSplit(); err != nil {
-					log.Printf("Error undoing split: %v", err)
-				}
-				g.lastEvent = "Undo"
-				g.eventTime = time.Now()
-				log.Println("Undo triggered")
-			}
+			g.undoSplit()
+
+		case <-hkSkip.Keydown():
+			g.skipSplit()
+
+		case <-hkPause.Keydown():
+			g.togglePause()
 
 		case <-hkReset.Keydown():
-			if g.isFinished && g.runManager.IsBetterThanPB() {
-				err := g.runManager.SaveAsPB()
-				if err != nil {
-					log.Printf("Error saving PB: %v", err)
-				}
-			}
-			if err := g.runManager.ResetRun(); err != nil {
-				log.Printf("Error resetting run: %v", err)
-			}
-			g.isFinished = false
-			g.lastEvent = "Reset"
-			g.eventTime = time.Now()
-			log.Println("Reset triggered")
+			longPress = nil
+			g.resetRun()
+		}
+	}
+}
+
+// setEvent shows event as the last thing that happened
+func (g *Game) setEvent(event string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.events.lastEvent = event
+	g.events.eventTime = time.Now()
+}
+
+// eventSnapshot returns a copy of the events for drawing
+func (g *Game) eventSnapshot() events {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.events
+}
+
+// handleSplitKey applies a split key press and shows what it did
+func (g *Game) handleSplitKey() {
+	if time.Since(g.lastSplitTime) < minSplitInterval {
+		log.Println("Ignoring split key bounce")
+		return
+	}
+	g.lastSplitTime = time.Now()
+
+	action, err := g.runManager.HandleSplitKey()
+	if err != nil {
+		log.Printf("Error recording split: %v", err)
+	}
+	var event string
+	newPB := false
+	switch action {
+	case speedrun.SplitKeyIgnored:
+		return
+	case speedrun.SplitKeyStarted:
+		event = "Started"
+	case speedrun.SplitKeySplit:
+		event = "Split"
+	case speedrun.SplitKeyFinished:
+		if err != nil || g.runManager.IsPracticeMode() {
+			event = "Finished"
+		} else if g.runManager.LastSaveWasPB() {
+			event = "Autosaved PB!"
+			newPB = true
+		} else {
+			event = "Saved to DB"
 		}
 	}
+	flashIndex := len(g.runManager.GetCurrentSplits()) - 1
+
+	now := time.Now()
+	g.mu.Lock()
+	g.events.lastEvent = event
+	g.events.eventTime = now
+	if newPB {
+		g.events.pbBannerTime = now
+	}
+	if action == speedrun.SplitKeySplit || action == speedrun.SplitKeyFinished {
+		g.events.splitFlashIndex = flashIndex
+		g.events.splitFlashTime = now
+	}
+	g.mu.Unlock()
+	log.Println("Split triggered")
+}
+
+// skipSplit skips the current split and shows it
+func (g *Game) skipSplit() {
+	if err := g.runManager.SkipSplit(); err != nil {
+		log.Printf("Error skipping split: %v", err)
+		return
+	}
+	g.setEvent("Skipped")
+	log.Println("Skip triggered")
+}
+
+// togglePause pauses the run, or resumes it if already paused
+func (g *Game) togglePause() {
+	if g.runManager.IsPaused() {
+		if err := g.runManager.ResumeRun(); err != nil {
+			log.Printf("Error resuming run: %v", err)
+			return
+		}
+		g.setEvent("Resumed")
+		return
+	}
+	if err := g.runManager.PauseRun(); err != nil {
+		log.Printf("Error pausing run: %v", err)
+		return
+	}
+	g.setEvent("Paused")
+}
+
+// undoSplit takes back the last split of a run in progress
+func (g *Game) undoSplit() {
+	if err := g.runManager.UndoSplit(); err != nil {
+		log.Printf("Error undoing split: %v", err)
+		return
+	}
+	g.setEvent("Undo")
+	log.Println("Undo triggered")
+}
+
+// resetRun saves a finished run as PB if it beat it, then resets
+func (g *Game) resetRun() {
+	if err := g.runManager.HandleResetKey(); err != nil {
+		log.Printf("Error resetting run: %v", err)
+	}
+	g.setEvent("Reset")
+	log.Println("Reset triggered")
 }
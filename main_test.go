@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestSplitRows(t *testing.T) {
+	tests := []struct {
+		name                           string
+		current, numSplits, maxVisible int
+		focus                          bool
+		wantFirst, wantLast            int
+	}{
+		{"all rows", 3, 10, 0, false, 0, 10},
+		{"focus hides done rows", 3, 10, 0, true, 3, 10},
+		{"focus on the last split", 9, 10, 0, true, 9, 10},
+		{"window centered on current", 5, 10, 4, false, 3, 7},
+		{"window at the top", 0, 10, 4, false, 0, 4},
+		{"window at the bottom", 9, 10, 4, false, 6, 10},
+		{"focus with a window", 5, 10, 4, true, 5, 9},
+		{"focus with fewer rows left than the window", 8, 10, 4, true, 8, 10},
+		{"window larger than the splits", 2, 3, 5, false, 0, 3},
+	}
+	for _, tt := range tests {
+		first, last := splitRows(tt.current, tt.numSplits, tt.maxVisible, tt.focus)
+		if first != tt.wantFirst || last != tt.wantLast {
+			t.Errorf("%s: splitRows(%d, %d, %d, %v) = %d, %d, want %d, %d", tt.name,
+				tt.current, tt.numSplits, tt.maxVisible, tt.focus, first, last, tt.wantFirst, tt.wantLast)
+		}
+	}
+}
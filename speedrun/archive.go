@@ -0,0 +1,128 @@
+package speedrun
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ArchiveRun hides a run from golds and stats without deleting it. The
+// current PB can't be archived.
+func (rm *RunManager) ArchiveRun(id int) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.pb != nil && rm.pb.ID == id {
+		return fmt.Errorf("cannot archive run %d: it is the personal best", id)
+	}
+	return rm.setArchived(id, true)
+}
+
+// UnarchiveRun brings back a run hidden by ArchiveRun
+func (rm *RunManager) UnarchiveRun(id int) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.setArchived(id, false)
+}
+
+// SetIncludeArchived controls whether archived runs still count towards golds
+// and stats
+func (rm *RunManager) SetIncludeArchived(include bool) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.includeArchived = include
+	return rm.computeBestSegments()
+}
+
+func (rm *RunManager) setArchived(id int, archived bool) error {
+	result, err := rm.db.Exec("UPDATE runs SET archived = ? WHERE id = ?", sqlite3Bool(archived), id)
+	if err != nil {
+		return fmt.Errorf("error archiving run %d: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error archiving run %d: %w", id, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no run with id %d", id)
+	}
+	return rm.computeBestSegments()
+}
+
+// DeleteRun removes a run and its splits for good. If it was the PB, the
+// fastest remaining completed run of its category becomes the PB. Golds are
+// recomputed from the runs that are left. The run just finished, still
+// shown until reset, can't be deleted: that returns ErrRunInProgress.
+func (rm *RunManager) DeleteRun(id int) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.isCompleted && rm.currentRunID == int64(id) {
+		return ErrRunInProgress
+	}
+
+	tx, err := rm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var isPB bool
+	var category string
+	err = tx.QueryRow("SELECT is_pb, category FROM runs WHERE id = ?", id).Scan(&isPB, &category)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no run with id %d", id)
+	}
+	if err != nil {
+		return fmt.Errorf("error loading run %d: %w", id, err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM splits WHERE run_id = ?", id); err != nil {
+		return fmt.Errorf("error deleting splits of run %d: %w", id, err)
+	}
+	if _, err := tx.Exec("DELETE FROM runs WHERE id = ?", id); err != nil {
+		return fmt.Errorf("error deleting run %d: %w", id, err)
+	}
+	if isPB {
+		if err := selectNewPB(tx, category); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	if rm.currentRunID == int64(id) {
+		rm.currentRunID = 0
+	}
+	rm.pb, err = loadPersonalBest(rm.db, rm.category)
+	if err != nil {
+		return fmt.Errorf("failed to reload PB after delete: %w", err)
+	}
+	return rm.computeBestSegments()
+}
+
+// selectNewPB marks the fastest completed, unarchived run of category with no
+// skipped splits as its PB. If there is none, the category is left without a
+// PB.
+func selectNewPB(tx *sql.Tx, category string) error {
+	var id int64
+	var totalNs int64
+	err := tx.QueryRow(`
+		SELECT runs.id, SUM(splits.duration_ns) AS total
+		FROM runs
+		JOIN splits ON splits.run_id = runs.id
+		WHERE runs.completed = 1 AND runs.archived = 0 AND runs.category = ?
+		GROUP BY runs.id
+		HAVING MIN(splits.duration_ns) > 0
+		ORDER BY total, runs.id
+		LIMIT 1
+	`, category).Scan(&id, &totalNs)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error finding new PB: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE runs SET is_pb = 1, pb_time_ns = ? WHERE id = ?", totalNs, id); err != nil {
+		return fmt.Errorf("error setting new PB: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package speedrun
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListRuns(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	recordRun(t, rm, 10*time.Second, 10*time.Second)
+	recordRun(t, rm, 12*time.Second)
+	recordRun(t, rm, 9*time.Second, 11*time.Second)
+
+	runs, err := rm.ListRuns(2, 0)
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID <= runs[1].ID {
+		t.Fatalf("ListRuns(2, 0) = %+v, want the two newest runs, newest first", runs)
+	}
+	if len(runs[0].Splits) != 2 || !runs[0].Completed {
+		t.Errorf("newest run = %+v, want the completed one with 2 splits", runs[0])
+	}
+	if len(runs[1].Splits) != 1 || runs[1].Completed {
+		t.Errorf("second run = %+v, want the unfinished one with 1 split", runs[1])
+	}
+
+	older, err := rm.ListRuns(2, 2)
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if len(older) != 1 || older[0].ID >= runs[1].ID {
+		t.Errorf("ListRuns(2, 2) = %+v, want only the oldest run", older)
+	}
+}
+
+func TestDeleteRun(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	recordRun(t, rm, 10*time.Second, 10*time.Second)
+	recordRun(t, rm, 9*time.Second, 9*time.Second)
+	runs, err := rm.ListRuns(2, 0)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("ListRuns = %d runs, %v", len(runs), err)
+	}
+	pb, slower := runs[0], runs[1]
+	if got := rm.GetPersonalBest(); got == nil || got.ID != pb.ID {
+		t.Fatalf("PB = %+v, want run %d", got, pb.ID)
+	}
+
+	if err := rm.DeleteRun(pb.ID); err != nil {
+		t.Fatalf("DeleteRun: %v", err)
+	}
+	if got := rm.GetPersonalBest(); got == nil || got.ID != slower.ID || got.PBTime != 20*time.Second {
+		t.Errorf("PB after deleting it = %+v, want run %d", got, slower.ID)
+	}
+	if golds := rm.GetGoldSegments(); golds[0] != 10*time.Second || golds[1] != 10*time.Second {
+		t.Errorf("golds = %v, want the remaining run's segments", golds)
+	}
+	if err := rm.DeleteRun(pb.ID); err == nil {
+		t.Error("deleting a run twice succeeded")
+	}
+}
+
+func TestDeleteRunRefusesCurrentRun(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	rm.StartRun()
+	for range 2 {
+		if _, err := rm.Split(); err != nil {
+			t.Fatalf("Split: %v", err)
+		}
+	}
+	runs, err := rm.ListRuns(1, 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListRuns = %d runs, %v", len(runs), err)
+	}
+
+	if err := rm.DeleteRun(runs[0].ID); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("DeleteRun of the finished run on screen = %v, want ErrRunInProgress", err)
+	}
+	if err := rm.ResetRun(); err != nil {
+		t.Fatalf("ResetRun: %v", err)
+	}
+	if err := rm.DeleteRun(runs[0].ID); err != nil {
+		t.Errorf("DeleteRun after reset: %v", err)
+	}
+}
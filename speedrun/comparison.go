@@ -0,0 +1,325 @@
+package speedrun
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ComparisonMode selects the segment times a run is measured against
+type ComparisonMode int
+
+const (
+	// ComparisonPB compares against the personal best run
+	ComparisonPB ComparisonMode = iota
+	// ComparisonBestSegments compares against the gold time of every split
+	ComparisonBestSegments
+	// ComparisonExternal compares against splits loaded by LoadComparisonFromJSON
+	ComparisonExternal
+	// ComparisonAverage compares against the average time of every split over
+	// the completed runs
+	ComparisonAverage
+	// ComparisonMedian compares against the median time of every split over the
+	// completed runs
+	ComparisonMedian
+	// ComparisonRunID compares against the run picked with SetComparisonMode
+	ComparisonRunID
+)
+
+// Comparisons lists every comparison, in the order they are cycled through
+var Comparisons = []ComparisonMode{ComparisonPB, ComparisonBestSegments, ComparisonAverage, ComparisonMedian, ComparisonExternal, ComparisonRunID}
+
+// ParseComparison turns a name like "pb" or "best" into a ComparisonMode
+func ParseComparison(name string) (ComparisonMode, error) {
+	switch name {
+	case "pb":
+		return ComparisonPB, nil
+	case "best":
+		return ComparisonBestSegments, nil
+	case "file":
+		return ComparisonExternal, nil
+	case "average":
+		return ComparisonAverage, nil
+	case "median":
+		return ComparisonMedian, nil
+	case "run":
+		return ComparisonRunID, nil
+	}
+	return ComparisonPB, fmt.Errorf("unknown comparison %q (want pb, best, average, median, file or run)", name)
+}
+
+// String returns the short label used in column headers
+func (c ComparisonMode) String() string {
+	switch c {
+	case ComparisonPB:
+		return "PB"
+	case ComparisonBestSegments:
+		return "Best"
+	case ComparisonExternal:
+		return "File"
+	case ComparisonAverage:
+		return "Avg"
+	case ComparisonMedian:
+		return "Median"
+	case ComparisonRunID:
+		return "Run"
+	}
+	return fmt.Sprintf("ComparisonMode(%d)", int(c))
+}
+
+// GetComparisonSegments returns the per-split segment times for c. The slice
+// may be shorter than the split list, or empty, if there is no data.
+func (rm *RunManager) GetComparisonSegments(c ComparisonMode) []time.Duration {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.comparisonSegments(c)
+}
+
+func (rm *RunManager) comparisonSegments(c ComparisonMode) []time.Duration {
+	switch c {
+	case ComparisonPB:
+		if rm.pb == nil {
+			return nil
+		}
+		segments := make([]time.Duration, len(rm.pb.Splits))
+		for i, split := range rm.pb.Splits {
+			segments[i] = split.Duration
+		}
+		return segments
+	case ComparisonBestSegments:
+		return append([]time.Duration(nil), rm.goldSegments...)
+	case ComparisonExternal:
+		return append([]time.Duration(nil), rm.externalComparison...)
+	case ComparisonAverage:
+		return append([]time.Duration(nil), rm.averageSegments...)
+	case ComparisonMedian:
+		return append([]time.Duration(nil), rm.medianSegments...)
+	case ComparisonRunID:
+		if rm.comparisonRun == nil {
+			return nil
+		}
+		segments := make([]time.Duration, len(rm.comparisonRun.Splits))
+		for i, split := range rm.comparisonRun.Splits {
+			segments[i] = split.Duration
+		}
+		return segments
+	}
+	return nil
+}
+
+// ComparisonLabel returns the header label for c, using the name given to
+// LoadComparisonFromJSON for external comparisons
+func (rm *RunManager) ComparisonLabel(c ComparisonMode) string {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	if c == ComparisonExternal && rm.externalComparisonLabel != "" {
+		return rm.externalComparisonLabel
+	}
+	if c == ComparisonRunID && rm.comparisonRun != nil {
+		return fmt.Sprintf("#%d", rm.comparisonRun.AttemptNum)
+	}
+	return c.String()
+}
+
+// WorstSplit returns the index of the split where run lost the most time
+// against comparison c, and how much was lost. Skipped splits and the split
+// right after them are ignored. If no split lost time, it returns -1 and 0.
+func (rm *RunManager) WorstSplit(run Run, c ComparisonMode) (index int, lossVsComparison time.Duration) {
+	rm.mu.RLock()
+	compareSegments := rm.comparisonSegments(c)
+	rm.mu.RUnlock()
+
+	segments := make([]time.Duration, len(run.Splits))
+	for i, split := range run.Splits {
+		segments[i] = split.Duration
+	}
+
+	index = -1
+	for i := range segments {
+		if i >= len(compareSegments) {
+			break
+		}
+		if !SegmentComparable(segments, i) || !SegmentComparable(compareSegments, i) {
+			continue
+		}
+		if loss := segments[i] - compareSegments[i]; loss > lossVsComparison {
+			index, lossVsComparison = i, loss
+		}
+	}
+	return index, lossVsComparison
+}
+
+// SetComparisonMode sets what the current run is compared against. For
+// ComparisonRunID, runID picks the run to load; without it the run loaded
+// last is kept. Modes with no times yet, like ComparisonPB before the first
+// completed run, are allowed.
+func (rm *RunManager) SetComparisonMode(mode ComparisonMode, runID ...int) error {
+	if !slices.Contains(Comparisons, mode) {
+		return fmt.Errorf("unknown comparison mode %d", int(mode))
+	}
+	if mode != ComparisonRunID {
+		if len(runID) > 0 {
+			return fmt.Errorf("a run ID is only used with ComparisonRunID, not %v", mode)
+		}
+		rm.mu.Lock()
+		defer rm.mu.Unlock()
+		rm.comparisonMode = mode
+		return nil
+	}
+
+	var run *Run
+	if len(runID) > 0 {
+		id := runID[0]
+		err := rm.forEachRun("runs.id = ?", []interface{}{id}, "runs.id", func(r *Run) error {
+			run = r
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if run == nil {
+			return fmt.Errorf("no run with id %d", id)
+		}
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if run != nil {
+		rm.comparisonRun = run
+	}
+	if rm.comparisonRun == nil {
+		return fmt.Errorf("no run to compare against: give its ID")
+	}
+	rm.comparisonMode = mode
+	return nil
+}
+
+// GetComparisonMode returns what the current run is compared against
+func (rm *RunManager) GetComparisonMode() ComparisonMode {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.comparisonMode
+}
+
+// ComputeAverageRun returns a run whose split times are the average of each
+// split over the completed runs. Skipped splits and the splits right after
+// them are left out, and a split with no times left is 0.
+func (rm *RunManager) ComputeAverageRun() (*Run, error) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	samples, err := rm.segmentSamples()
+	if err != nil {
+		return nil, err
+	}
+	return rm.statRun(averageSegments(samples)), nil
+}
+
+// ComputeMedianRun is ComputeAverageRun with the median instead of the
+// average
+func (rm *RunManager) ComputeMedianRun() (*Run, error) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	samples, err := rm.segmentSamples()
+	if err != nil {
+		return nil, err
+	}
+	return rm.statRun(medianSegments(samples)), nil
+}
+
+// refreshStatComparisons recomputes the average and median comparisons. It
+// must be called whenever the completed runs change.
+func (rm *RunManager) refreshStatComparisons() error {
+	samples, err := rm.segmentSamples()
+	if err != nil {
+		return err
+	}
+	rm.averageSegments = averageSegments(samples)
+	rm.medianSegments = medianSegments(samples)
+	return nil
+}
+
+// statRun wraps per-split times computed from many runs in a Run
+func (rm *RunManager) statRun(segments []time.Duration) *Run {
+	run := &Run{Title: rm.title, Category: rm.category, Completed: true}
+	for i, d := range segments {
+		run.appendSplit(rm.splitNames[i], d)
+	}
+	return run
+}
+
+// segmentSamples returns every single-segment time of every split over the
+// completed runs of the current category, by split index, the same way computeBestSegments reads
+// them
+func (rm *RunManager) segmentSamples() ([][]time.Duration, error) {
+	rows, err := rm.db.Query(`
+		SELECT splits.run_id, splits.split_index, splits.duration_ns
+		FROM splits
+		JOIN runs ON splits.run_id = runs.id
+		WHERE runs.completed = 1 AND runs.category = ? AND (? OR runs.archived = 0)
+		ORDER BY splits.run_id, splits.split_index
+	`, rm.category, sqlite3Bool(rm.includeArchived))
+	if err != nil {
+		return nil, fmt.Errorf("error loading segment times: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make([][]time.Duration, rm.numSplits)
+	var prevRunID int64 = -1
+	var prevDur time.Duration
+	for rows.Next() {
+		var runID int64
+		var idx int
+		var durNs int64
+		if err := rows.Scan(&runID, &idx, &durNs); err != nil {
+			return nil, fmt.Errorf("error scanning segment time: %w", err)
+		}
+		d := time.Duration(durNs)
+		afterSkip := runID == prevRunID && prevDur == 0
+		prevRunID, prevDur = runID, d
+		if d <= 0 || afterSkip || idx < 0 || idx >= rm.numSplits {
+			continue
+		}
+		samples[idx] = append(samples[idx], d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading segment times: %w", err)
+	}
+	return samples, nil
+}
+
+func averageSegments(samples [][]time.Duration) []time.Duration {
+	segments := make([]time.Duration, len(samples))
+	for i, times := range samples {
+		if len(times) == 0 {
+			continue
+		}
+		var total time.Duration
+		for _, d := range times {
+			total += d
+		}
+		segments[i] = total / time.Duration(len(times))
+	}
+	return segments
+}
+
+func medianSegments(samples [][]time.Duration) []time.Duration {
+	segments := make([]time.Duration, len(samples))
+	for i, times := range samples {
+		segments[i] = median(times)
+	}
+	return segments
+}
+
+// median returns the median of times, or 0 if there are none
+func median(times []time.Duration) time.Duration {
+	n := len(times)
+	if n == 0 {
+		return 0
+	}
+	sorted := slices.Clone(times)
+	slices.Sort(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
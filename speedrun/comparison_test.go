@@ -0,0 +1,137 @@
+package speedrun
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// cumulative returns the running totals of segments
+func cumulative(segments []time.Duration) []time.Duration {
+	totals := make([]time.Duration, len(segments))
+	var total time.Duration
+	for i, d := range segments {
+		total += d
+		totals[i] = total
+	}
+	return totals
+}
+
+func TestComparisonBaselines(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B", "C")
+	recordRun(t, rm, 10*time.Second, 20*time.Second, 30*time.Second)
+	// Slower overall, but with a gold on B
+	recordRun(t, rm, 12*time.Second, 15*time.Second, 40*time.Second)
+
+	// The diff column measures against the PB while the projection shows
+	// the golds, each from its own comparison
+	diffBaseline := cumulative(rm.GetComparisonSegments(ComparisonPB))
+	projection := cumulative(rm.GetComparisonSegments(ComparisonBestSegments))
+	wantDiff := []time.Duration{10 * time.Second, 30 * time.Second, 60 * time.Second}
+	wantProjection := []time.Duration{10 * time.Second, 25 * time.Second, 55 * time.Second}
+	if !slices.Equal(diffBaseline, wantDiff) {
+		t.Errorf("PB baseline = %v, want %v", diffBaseline, wantDiff)
+	}
+	if !slices.Equal(projection, wantProjection) {
+		t.Errorf("best segments projection = %v, want %v", projection, wantProjection)
+	}
+
+	// The average of the two runs is a third, separate baseline
+	wantAverage := []time.Duration{11 * time.Second, 17500 * time.Millisecond, 35 * time.Second}
+	if average := rm.GetComparisonSegments(ComparisonAverage); !slices.Equal(average, wantAverage) {
+		t.Errorf("average baseline = %v, want %v", average, wantAverage)
+	}
+}
+
+func TestWorstSplit(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B", "C", "D")
+	recordRun(t, rm, 10*time.Second, 20*time.Second, 30*time.Second, 40*time.Second)
+
+	runOf := func(segments ...time.Duration) Run {
+		var run Run
+		for _, d := range segments {
+			run.Splits = append(run.Splits, Split{Duration: d})
+		}
+		return run
+	}
+	tests := []struct {
+		name      string
+		run       Run
+		wantIndex int
+		wantLoss  time.Duration
+	}{
+		{"biggest loss", runOf(11*time.Second, 25*time.Second, 31*time.Second, 40*time.Second), 1, 5 * time.Second},
+		{"no losses", runOf(9*time.Second, 20*time.Second, 29*time.Second, 40*time.Second), -1, 0},
+		// C after the skip holds B's time too, so its big "loss" is ignored
+		{"skip", runOf(12*time.Second, 0, 60*time.Second, 41*time.Second), 0, 2 * time.Second},
+		{"unfinished run", runOf(10 * time.Second), -1, 0},
+	}
+	for _, tt := range tests {
+		index, loss := rm.WorstSplit(tt.run, ComparisonPB)
+		if index != tt.wantIndex || loss != tt.wantLoss {
+			t.Errorf("%s: WorstSplit = %d, %v, want %d, %v", tt.name, index, loss, tt.wantIndex, tt.wantLoss)
+		}
+	}
+
+	// Without times to compare to, nothing lost time
+	if index, loss := rm.WorstSplit(tests[0].run, ComparisonExternal); index != -1 || loss != 0 {
+		t.Errorf("WorstSplit with no comparison times = %d, %v, want -1, 0", index, loss)
+	}
+}
+
+func TestSetComparisonMode(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	recordRun(t, rm, 10*time.Second, 20*time.Second)
+	recordRun(t, rm, 12*time.Second, 15*time.Second)
+	runs, err := rm.ListRuns(1, 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListRuns = %d runs, %v", len(runs), err)
+	}
+
+	if mode := rm.GetComparisonMode(); mode != ComparisonPB {
+		t.Errorf("default mode = %v, want PB", mode)
+	}
+	if err := rm.SetComparisonMode(ComparisonMedian); err != nil {
+		t.Fatalf("SetComparisonMode(Median): %v", err)
+	}
+	if mode := rm.GetComparisonMode(); mode != ComparisonMedian {
+		t.Errorf("mode = %v, want Median", mode)
+	}
+
+	// The run mode needs a run, and refuses IDs that don't exist
+	if err := rm.SetComparisonMode(ComparisonRunID); err == nil {
+		t.Error("SetComparisonMode(RunID) with no run loaded succeeded")
+	}
+	if err := rm.SetComparisonMode(ComparisonRunID, 999); err == nil {
+		t.Error("SetComparisonMode(RunID, 999) succeeded")
+	}
+	if mode := rm.GetComparisonMode(); mode != ComparisonMedian {
+		t.Errorf("mode after failed changes = %v, want Median", mode)
+	}
+
+	if err := rm.SetComparisonMode(ComparisonRunID, runs[0].ID); err != nil {
+		t.Fatalf("SetComparisonMode(RunID, %d): %v", runs[0].ID, err)
+	}
+	want := []time.Duration{12 * time.Second, 15 * time.Second}
+	if got := rm.GetComparisonSegments(rm.GetComparisonMode()); !slices.Equal(got, want) {
+		t.Errorf("run comparison = %v, want %v", got, want)
+	}
+
+	// Switching away and back keeps the loaded run
+	if err := rm.SetComparisonMode(ComparisonPB); err != nil {
+		t.Fatalf("SetComparisonMode(PB): %v", err)
+	}
+	if err := rm.SetComparisonMode(ComparisonRunID); err != nil {
+		t.Fatalf("SetComparisonMode(RunID) after loading a run: %v", err)
+	}
+	if got := rm.GetComparisonSegments(ComparisonRunID); !slices.Equal(got, want) {
+		t.Errorf("run comparison after switching back = %v, want %v", got, want)
+	}
+
+	if err := rm.SetComparisonMode(ComparisonPB, runs[0].ID); err == nil {
+		t.Error("SetComparisonMode(PB) with a run ID succeeded")
+	}
+	if err := rm.SetComparisonMode(ComparisonMode(42)); err == nil {
+		t.Error("SetComparisonMode with an unknown mode succeeded")
+	}
+}
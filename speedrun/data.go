@@ -2,13 +2,29 @@ package speedrun
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"os"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrIndexOutOfRange is returned when a split index doesn't exist
+var ErrIndexOutOfRange = errors.New("split index out of range")
+
+// ErrRunInProgress is returned when changing the splits while a run is going,
+// or deleting the finished run still shown
+var ErrRunInProgress = errors.New("a run is in progress")
+
+// ErrDatabaseNotFound is returned by NewRunManagerWithOptions when the
+// database doesn't exist and NoCreate is set
+var ErrDatabaseNotFound = errors.New("database does not exist")
+
 // Split represents a single segment of time in a run
 type Split struct {
 	Name        string
@@ -16,6 +32,26 @@ type Split struct {
 	// NEW: Holds the best (gold) segment time across *all* runs for this split index.
 	// This is computed in memory by scanning the DB. Not necessarily from this run.
 	BestSegment time.Duration
+	// AbsoluteTime is the run time at the end of this split. A skipped split
+	// has the same time as the one before it.
+	AbsoluteTime time.Duration
+	// IsGold is only filled in by PBSegments
+	IsGold bool
+	// Note is the note set with SetSplitNote
+	Note string
+}
+
+// SplitNode is a split name along with its nesting depth (0 = top-level)
+type SplitNode struct {
+	Name  string
+	Depth int
+}
+
+// splitStart is when a split started, and how long the run had been paused
+// by then
+type splitStart struct {
+	at          time.Time
+	pausedTotal time.Duration
 }
 
 // Run represents a complete speedrun attempt
@@ -29,18 +65,79 @@ type Run struct {
 	IsPB       bool
 	AttemptNum int
 	Splits     []Split
+	// GameVersion is the version set with SetGameVersion, if any
+	GameVersion string
+	// PBTime is the total time stored when the run became the PB. It is
+	// only filled in for the PB.
+	PBTime time.Duration
+}
+
+// TimeSince returns the cumulative run time from the first split through
+// splitIndex. It returns 0 if splitIndex is out of range. It uses the split's
+// AbsoluteTime when set, and sums the durations otherwise.
+func (r *Run) TimeSince(splitIndex int) time.Duration {
+	if splitIndex < 0 || splitIndex >= len(r.Splits) {
+		return 0
+	}
+	if t := r.Splits[splitIndex].AbsoluteTime; t > 0 {
+		return t
+	}
+	var total time.Duration
+	for _, split := range r.Splits[:splitIndex+1] {
+		total += split.Duration
+	}
+	return total
+}
+
+// appendSplit adds a split of duration d to the run, with its AbsoluteTime
+func (r *Run) appendSplit(name string, d time.Duration) {
+	split := Split{Name: name, Duration: d, AbsoluteTime: d}
+	if n := len(r.Splits); n > 0 {
+		split.AbsoluteTime += r.Splits[n-1].AbsoluteTime
+	}
+	r.Splits = append(r.Splits, split)
+}
+
+// SplitNames returns the names of the run's splits, in order
+func (r *Run) SplitNames() []string {
+	names := make([]string, len(r.Splits))
+	for i, split := range r.Splits {
+		names[i] = split.Name
+	}
+	return names
 }
 
-// RunManager handles all speedrun data operations
+// RunManager handles all speedrun data operations. It is safe for concurrent
+// use: exported methods take mu, unexported ones expect the caller to hold it.
 type RunManager struct {
+	mu            sync.RWMutex
 	db            *sql.DB
+	closed        bool
+	debug         bool // see NewRunManagerOptions.Debug
 	title         string
 	category      string
 	attempts      int
 	completedRuns int
 	splitNames    []string
+	splitDepths   []int
+	numSplits     int // len(splitNames), kept in sync by setSplitNames
 	splits        []time.Duration
 	pb            *Run
+	// goldSegments holds the best time for each split index, zero if none
+	goldSegments []time.Duration
+
+	// externalComparison holds segment times loaded by LoadComparisonFromJSON
+	externalComparison      []time.Duration
+	externalComparisonLabel string
+	// averageSegments and medianSegments are the ComparisonAverage and
+	// ComparisonMedian times, kept up to date by refreshStatComparisons
+	averageSegments []time.Duration
+	medianSegments  []time.Duration
+	// comparisonRun is the run loaded by SetComparisonMode, for
+	// ComparisonRunID
+	comparisonRun *Run
+	// comparisonMode is what the current run is compared against
+	comparisonMode ComparisonMode
 
 	// Run state
 	startTime      time.Time
@@ -48,257 +145,845 @@ type RunManager struct {
 	isRunning      bool
 	currentSplit   int
 	isCompleted    bool
+
+	// pausedAt is when the run was paused, zero while it isn't. pausedTotal
+	// is how long the whole run has been paused and splitPaused how long the
+	// current split has.
+	pausedAt    time.Time
+	pausedTotal time.Duration
+	splitPaused time.Duration
+	// splitStarts holds the start of each recorded split, parallel to
+	// splits, so UndoSplit can resume a split where it was
+	splitStarts []splitStart
+
+	// currentRunID is the database ID of the run most recently saved by saveRun
+	currentRunID int64
+	// lastSaveWasPB records whether the most recent saveRun set a new PB
+	lastSaveWasPB bool
+
+	// autoStart makes a split press after finishing reset and start a fresh run
+	autoStart bool
+	// includeArchived counts runs hidden by ArchiveRun towards golds and stats
+	includeArchived bool
+	// practice keeps runs out of the database entirely
+	practice bool
+}
+
+// SplitKeyAction describes what HandleSplitKey did
+type SplitKeyAction int
+
+const (
+	SplitKeyIgnored SplitKeyAction = iota
+	SplitKeyStarted
+	SplitKeySplit
+	SplitKeyFinished
+)
+
+// NewRunManagerOptions controls how NewRunManagerWithOptions opens a database
+type NewRunManagerOptions struct {
+	// AutoMigrate applies pending schema migrations while opening. When
+	// false and migrations are pending, ErrMigrationRequired is returned.
+	AutoMigrate bool
+	// NoCreate makes a missing database an ErrDatabaseNotFound error, rather
+	// than creating a new, empty one
+	NoCreate bool
+	// Debug logs warnings about misuse that is otherwise ignored, like
+	// closing the RunManager twice
+	Debug bool
 }
 
+// DefaultNewRunManagerOptions are the options used by NewRunManager
+var DefaultNewRunManagerOptions = NewRunManagerOptions{AutoMigrate: true}
+
 // NewRunManager creates and initializes a new RunManager
 func NewRunManager(dbPath string) (*RunManager, error) {
+	return NewRunManagerWithOptions(dbPath, DefaultNewRunManagerOptions)
+}
+
+// NewRunManagerWithOptions creates and initializes a new RunManager. If
+// migrations are pending and opts.AutoMigrate is false, it returns the
+// RunManager together with ErrMigrationRequired; until Migrate succeeds, only
+// Migrate and Close may be called on it.
+func NewRunManagerWithOptions(dbPath string, opts NewRunManagerOptions) (*RunManager, error) {
+	// SQLite silently creates missing files, so check first
+	if _, err := os.Stat(dbPath); errors.Is(err, fs.ErrNotExist) {
+		if opts.NoCreate {
+			return nil, fmt.Errorf("%s: %w", dbPath, ErrDatabaseNotFound)
+		}
+		log.Printf("Database %s does not exist, creating a new one", dbPath)
+	}
+
 	// Connect to SQLite database
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Initialize database schema
 	if err := initDatabase(db); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to initialize database: %v", err)
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	// Load config from database
-	title, category, attempts, completed, splitNames, err := loadConfig(db)
+	rm := &RunManager{db: db, debug: opts.Debug}
+
+	pending, err := pendingMigrations(db)
 	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to load config: %v", err)
+		return nil, fmt.Errorf("failed to check migrations: %w", err)
+	}
+	if len(pending) > 0 {
+		if !opts.AutoMigrate {
+			return rm, ErrMigrationRequired
+		}
+		if err := applyMigrations(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	if err := rm.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return rm, nil
+}
+
+// load reads config, split names, the PB and golds from the database
+func (rm *RunManager) load() error {
+	// Load config from database
+	title, category, attempts, completed, splitNames, err := loadConfig(rm.db)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	splitDepths, err := loadSplitDepths(rm.db)
+	if err != nil {
+		return fmt.Errorf("failed to load split depths: %w", err)
 	}
 
 	// Load personal best
-	pb, err := loadPersonalBest(db)
+	pb, err := loadPersonalBest(rm.db, category)
 	if err != nil {
 		log.Printf("Warning: Failed to load personal best: %v", err)
 	}
 
-	rm := &RunManager{
-		db:            db,
-		title:         title,
-		category:      category,
-		attempts:      attempts,
-		completedRuns: completed,
-		splitNames:    splitNames,
-		splits:        make([]time.Duration, 0, len(splitNames)),
-		pb:            pb,
+	rm.title = title
+	rm.category = category
+	rm.attempts = attempts
+	rm.completedRuns = completed
+	rm.setSplitNames(splitNames, splitDepths)
+	rm.splits = make([]time.Duration, 0, rm.numSplits)
+	rm.pb = pb
+
+	// Load the stored golds, computing them from the run history the first
+	// time or when archived runs should count too
+	golds, found, err := loadBestSegments(rm.db, rm.numSplits)
+	if err != nil {
+		log.Printf("Warning: Could not load best segments: %v", err)
 	}
-
-	// NEW: If we have a PB, also compute the best (gold) segment times
-	if pb != nil {
-		if err := rm.ComputeBestSegments(); err != nil {
-			log.Printf("Warning: Could not compute best segments: %v", err)
+	if found && !rm.includeArchived {
+		rm.goldSegments = golds
+		rm.applyGoldsToPB()
+		if err := rm.refreshStatComparisons(); err != nil {
+			log.Printf("Warning: Could not compute average and median: %v", err)
 		}
+	} else if err := rm.computeBestSegments(); err != nil {
+		log.Printf("Warning: Could not compute best segments: %v", err)
 	}
 
-	return rm, nil
+	return nil
 }
 
-// Close releases database resources
+// RunManager is an io.Closer
+var _ io.Closer = (*RunManager)(nil)
+
+// Close releases database resources. Calls after the first are no-ops.
 func (rm *RunManager) Close() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.closed {
+		if rm.debug {
+			log.Printf("Warning: RunManager closed more than once")
+		}
+		return nil
+	}
+	rm.closed = true
 	return rm.db.Close()
 }
 
 // GetTitle returns the speedrun title
 func (rm *RunManager) GetTitle() string {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.title
 }
 
 // GetCategory returns the speedrun category
 func (rm *RunManager) GetCategory() string {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.category
 }
 
 // GetAttempts returns the total number of attempts
 func (rm *RunManager) GetAttempts() int {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.attempts
 }
 
+// GetCurrentAttemptNumber returns the attempt number the in-progress run will
+// be saved with
+func (rm *RunManager) GetCurrentAttemptNumber() int {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.attempts + 1
+}
+
 // GetCompletedRuns returns the number of completed runs
 func (rm *RunManager) GetCompletedRuns() int {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.completedRuns
 }
 
-// GetSplitNames returns the list of split names
+// GetCompletionRateByCategory returns, for every category with recorded runs,
+// the fraction of those runs that were completed (0 to 1)
+func (rm *RunManager) GetCompletionRateByCategory() (map[string]float64, error) {
+	rm.mu.RLock()
+	includeArchived := rm.includeArchived
+	rm.mu.RUnlock()
+
+	rows, err := rm.db.Query("SELECT category, COUNT(*), SUM(completed) FROM runs WHERE (? OR archived = 0) GROUP BY category",
+		sqlite3Bool(includeArchived))
+	if err != nil {
+		return nil, fmt.Errorf("error loading completion rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := make(map[string]float64)
+	for rows.Next() {
+		var category string
+		var total, done int
+		if err := rows.Scan(&category, &total, &done); err != nil {
+			return nil, fmt.Errorf("error scanning completion rate: %w", err)
+		}
+		if total > 0 {
+			rates[category] = float64(done) / float64(total)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading completion rates: %w", err)
+	}
+	return rates, nil
+}
+
+// GetSplitNames returns a copy of the list of split names
 func (rm *RunManager) GetSplitNames() []string {
-	return rm.splitNames
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return append([]string(nil), rm.splitNames...)
+}
+
+// GetSplitNamesWithDepth returns the split names along with their sub-split depth
+func (rm *RunManager) GetSplitNamesWithDepth() []SplitNode {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.splitNodes()
+}
+
+func (rm *RunManager) splitNodes() []SplitNode {
+	nodes := make([]SplitNode, rm.numSplits)
+	for i, name := range rm.splitNames {
+		nodes[i].Name = name
+		if i < len(rm.splitDepths) {
+			nodes[i].Depth = rm.splitDepths[i]
+		}
+	}
+	return nodes
 }
 
-// GetCurrentSplits returns the current split times
+// GetCurrentSplits returns a copy of the current split times
 func (rm *RunManager) GetCurrentSplits() []time.Duration {
-	return rm.splits
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return append([]time.Duration(nil), rm.splits...)
+}
+
+// GetCurrentRun returns the splits recorded so far in the current run
+func (rm *RunManager) GetCurrentRun() Run {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	run := Run{
+		Title:      rm.title,
+		Category:   rm.category,
+		StartTime:  rm.startTime,
+		Completed:  rm.isCompleted,
+		AttemptNum: rm.attempts,
+		Splits:     make([]Split, 0, len(rm.splits)),
+	}
+	if rm.isRunning {
+		run.AttemptNum = rm.attempts + 1
+	}
+	for i, d := range rm.splits {
+		run.appendSplit(rm.splitNames[i], d)
+	}
+	return run
 }
 
-// GetPersonalBest returns the personal best run
+// GetPersonalBest returns a copy of the personal best run
 func (rm *RunManager) GetPersonalBest() *Run {
-	return rm.pb
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return copyRun(rm.pb)
+}
+
+// GetPersonalBestTime returns the total time of the PB, or 0 if there is none
+func (rm *RunManager) GetPersonalBestTime() time.Duration {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	if rm.pb == nil {
+		return 0
+	}
+	return rm.pb.PBTime
+}
+
+// GetPBSegmentDuration returns the PB's segment time for split i, or 0 if
+// there is no PB, i is out of range or the PB skipped it
+func (rm *RunManager) GetPBSegmentDuration(i int) time.Duration {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	if rm.pb == nil || i < 0 || i >= len(rm.pb.Splits) {
+		return 0
+	}
+	return rm.pb.Splits[i].Duration
+}
+
+// noGold is the placeholder ComputeBestSegments starts each gold from. It is
+// replaced by zero for splits that never got a time.
+const noGold = time.Duration(1<<63 - 1)
+
+// GetSumOfBest returns the sum of the golds of all splits, the fastest time
+// the run could ever be finished in. It returns zero while any split has no
+// gold yet, since the sum would be meaningless.
+func (rm *RunManager) GetSumOfBest() time.Duration {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	var sum time.Duration
+	for i := 0; i < rm.numSplits; i++ {
+		gold := rm.gold(i)
+		if gold == 0 {
+			return 0
+		}
+		sum += gold
+	}
+	return sum
+}
+
+// GetBestPossibleTime returns the fastest time the current run can still
+// finish in: the time so far plus the gold of every split still to come, with
+// the current split counting as at least the time already spent on it.
+// Splits without a gold add nothing; MissingGolds says how many there are.
+func (rm *RunManager) GetBestPossibleTime() time.Duration {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	bpt, _ := rm.bestPossibleTime()
+	return bpt
+}
+
+// MissingGolds returns how many of the splits still to come have no gold,
+// and so are left out of GetBestPossibleTime
+func (rm *RunManager) MissingGolds() int {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	_, missing := rm.bestPossibleTime()
+	return missing
+}
+
+func (rm *RunManager) bestPossibleTime() (time.Duration, int) {
+	var bpt time.Duration
+	for _, d := range rm.splits {
+		bpt += d
+	}
+	if rm.isCompleted {
+		return bpt, 0
+	}
+
+	missing := 0
+	for i := len(rm.splits); i < rm.numSplits; i++ {
+		gold := rm.gold(i)
+		if gold == 0 {
+			missing++
+		}
+		if i == len(rm.splits) && rm.isRunning {
+			// The current segment also covers the skipped splits before it
+			for j := i - 1; j >= 0 && rm.splits[j] == 0; j-- {
+				gold += rm.gold(j)
+			}
+			gold = max(gold, rm.currentSplitTime())
+		}
+		bpt += gold
+	}
+	return bpt, missing
+}
+
+// gold returns the gold of split i, or zero if it has none
+func (rm *RunManager) gold(i int) time.Duration {
+	if i >= len(rm.goldSegments) || rm.goldSegments[i] <= 0 || rm.goldSegments[i] == noGold {
+		return 0
+	}
+	return rm.goldSegments[i]
+}
+
+// GetGoldSegments returns the best time for each split index across all
+// completed runs. Splits without a usable time are zero.
+func (rm *RunManager) GetGoldSegments() []time.Duration {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return append([]time.Duration(nil), rm.goldSegments...)
 }
 
 // IsRunning returns whether a run is in progress
 func (rm *RunManager) IsRunning() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.isRunning
 }
 
 // IsCompleted returns whether the current run is completed
 func (rm *RunManager) IsCompleted() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.isCompleted
 }
 
 // GetCurrentSplit returns the index of the current split
 func (rm *RunManager) GetCurrentSplit() int {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.currentSplit
 }
 
+// GetSplitNameByIndex returns the name of split i, or ErrIndexOutOfRange
+func (rm *RunManager) GetSplitNameByIndex(i int) (string, error) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.splitNameByIndex(i)
+}
+
+func (rm *RunManager) splitNameByIndex(i int) (string, error) {
+	if i < 0 || i >= rm.numSplits {
+		return "", ErrIndexOutOfRange
+	}
+	return rm.splitNames[i], nil
+}
+
+// GetCurrentSplitName returns the name of the current split, or "" if there is none
+func (rm *RunManager) GetCurrentSplitName() string {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	name, _ := rm.splitNameByIndex(rm.currentSplit)
+	return name
+}
+
 // GetStartTime returns when the current run started
 func (rm *RunManager) GetStartTime() time.Time {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.startTime
 }
 
 // GetSplitStartTime returns when the current split started
 func (rm *RunManager) GetSplitStartTime() time.Time {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 	return rm.splitStartTime
 }
 
 // StartRun begins a new speedrun
 func (rm *RunManager) StartRun() {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.startRun()
+}
+
+func (rm *RunManager) startRun() {
 	rm.isRunning = true
 	rm.startTime = time.Now()
 	rm.splitStartTime = rm.startTime
 	rm.currentSplit = 0
-	rm.splits = make([]time.Duration, 0, len(rm.splitNames))
+	rm.splits = make([]time.Duration, 0, rm.numSplits)
+	rm.splitStarts = make([]splitStart, 0, rm.numSplits)
 	rm.isCompleted = false
+	rm.clearPause()
 }
 
-// Split records the current split and moves to the next one
-// Returns whether this was the final split
-func (rm *RunManager) Split() (bool, error) {
-	if !rm.isRunning || rm.currentSplit >= len(rm.splitNames) {
-		return false, fmt.Errorf("cannot split: run not active or all splits completed")
+// SplitResult describes a split recorded by Split
+type SplitResult struct {
+	IsLastSplit bool
+	// IsNewGold is set when the segment beat the best time for this split
+	IsNewGold bool
+	// DiffVsPB is the run time minus the PB's time at this split, zero if
+	// the PB has no time for it
+	DiffVsPB    time.Duration
+	SegmentTime time.Duration
+}
+
+// Split records the current split and moves to the next one. It returns
+// ErrPaused while the run is paused: resume it first.
+func (rm *RunManager) Split() (SplitResult, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.split()
+}
+
+func (rm *RunManager) split() (SplitResult, error) {
+	if !rm.isRunning || rm.currentSplit >= rm.numSplits {
+		return SplitResult{}, fmt.Errorf("cannot split: run not active or all splits completed")
+	}
+	if rm.isPaused() {
+		return SplitResult{}, ErrPaused
 	}
 
 	// Record split time
-	splitDuration := time.Since(rm.splitStartTime)
+	splitDuration := rm.currentSplitTime()
 	rm.splits = append(rm.splits, splitDuration)
+	rm.pushSplitStart()
+
+	i := rm.currentSplit
+	result := SplitResult{
+		IsLastSplit: i == rm.numSplits-1,
+		SegmentTime: splitDuration,
+	}
+	// Compare before saveRun recomputes the golds with this run included
+	if SegmentComparable(rm.splits, i) {
+		gold := time.Duration(0)
+		if i < len(rm.goldSegments) {
+			gold = rm.goldSegments[i]
+		}
+		result.IsNewGold = gold == 0 || splitDuration < gold
+	}
+	if rm.pb != nil && i < len(rm.pb.Splits) {
+		var cumulative time.Duration
+		for _, split := range rm.splits {
+			cumulative += split
+		}
+		result.DiffVsPB = cumulative - rm.pb.TimeSince(i)
+	}
 
-	isLastSplit := rm.currentSplit == len(rm.splitNames)-1
-	if isLastSplit {
+	if result.IsLastSplit {
 		// This was the last split
 		rm.isRunning = false
 		rm.isCompleted = true
 
 		// Save completed run to database
-		if err := rm.saveRun(true); err != nil {
-			return true, fmt.Errorf("error saving completed run: %v", err)
+		if err := rm.saveRun(true, ""); err != nil {
+			return result, fmt.Errorf("error saving completed run: %w", err)
 		}
 	} else {
 		// Start next split
 		rm.currentSplit++
 		rm.splitStartTime = time.Now()
+		rm.splitPaused = 0
 	}
 
-	return isLastSplit, nil
+	return result, nil
 }
 
-// UndoSplit removes the last split and goes back
+// SkipSplit moves to the next split without recording a time. The skipped
+// split is stored as zero and its time carries over into the next split.
+// Like Split, it returns ErrPaused while the run is paused.
+func (rm *RunManager) SkipSplit() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if !rm.isRunning || rm.currentSplit >= rm.numSplits {
+		return fmt.Errorf("cannot skip: run not active or all splits completed")
+	}
+	if rm.isPaused() {
+		return ErrPaused
+	}
+	if rm.currentSplit == rm.numSplits-1 {
+		return fmt.Errorf("cannot skip the last split")
+	}
+
+	rm.splits = append(rm.splits, 0)
+	rm.pushSplitStart()
+	rm.currentSplit++
+	return nil
+}
+
+// pushSplitStart remembers the start of the split just recorded. A skipped
+// split shares its start with the next one, since its time carries over.
+func (rm *RunManager) pushSplitStart() {
+	rm.splitStarts = append(rm.splitStarts, splitStart{
+		at:          rm.splitStartTime,
+		pausedTotal: rm.pausedTotal - rm.splitPaused,
+	})
+}
+
+// IsOnLastSplit returns whether the run is on its final split
+func (rm *RunManager) IsOnLastSplit() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.isRunning && rm.currentSplit == rm.numSplits-1
+}
+
+// SetAutoStart controls whether a split press after finishing starts a new run.
+// When disabled, the finished run must be reset first.
+func (rm *RunManager) SetAutoStart(enabled bool) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.autoStart = enabled
+}
+
+// SetPracticeMode turns practice mode on or off. Practice runs are never
+// saved, don't count as attempts and can't become the PB.
+func (rm *RunManager) SetPracticeMode(enabled bool) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.practice = enabled
+}
+
+// IsPracticeMode returns whether practice mode is on
+func (rm *RunManager) IsPracticeMode() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.practice
+}
+
+// HandleSplitKey applies a press of the start/split key: it starts a run when
+// stopped, splits while running, and after a finish either ignores the press
+// or, with auto-start, resets and starts fresh. Presses while paused are
+// ignored with ErrPaused.
+func (rm *RunManager) HandleSplitKey() (SplitKeyAction, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.isPaused() {
+		return SplitKeyIgnored, ErrPaused
+	}
+
+	if rm.isCompleted {
+		if !rm.autoStart {
+			return SplitKeyIgnored, nil
+		}
+		if err := rm.resetRun(""); err != nil {
+			return SplitKeyIgnored, err
+		}
+	}
+
+	if !rm.isRunning {
+		rm.startRun()
+		return SplitKeyStarted, nil
+	}
+
+	result, err := rm.split()
+	if result.IsLastSplit {
+		return SplitKeyFinished, err
+	}
+	return SplitKeySplit, err
+}
+
+// UndoSplit removes the last split and goes back. Like Split, it returns
+// ErrPaused while the run is paused.
 func (rm *RunManager) UndoSplit() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 	if !rm.isRunning || len(rm.splits) == 0 {
 		return fmt.Errorf("cannot undo: run not active or no splits recorded")
 	}
+	if rm.isPaused() {
+		return ErrPaused
+	}
 
-	// Remove last split and go back
+	// Remove last split and go back to it, with the time already spent on it
 	rm.splits = rm.splits[:len(rm.splits)-1]
 	rm.currentSplit--
-	rm.splitStartTime = time.Now()
+	start := rm.splitStarts[len(rm.splitStarts)-1]
+	rm.splitStarts = rm.splitStarts[:len(rm.splitStarts)-1]
+	rm.splitStartTime = start.at
+	rm.splitPaused = rm.pausedTotal - start.pausedTotal
 	rm.isCompleted = false
 
 	return nil
 }
 
+// HandleResetKey applies a press of the reset key: a finished run that beat
+// the PB is saved as PB, then the run is reset. Both happen under one lock, so
+// a run can't finish or be saved again in between.
+func (rm *RunManager) HandleResetKey() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.isBetterThanPB() {
+		if err := rm.saveAsPB(); err != nil {
+			return fmt.Errorf("error saving PB: %w", err)
+		}
+	}
+	return rm.resetRun("")
+}
+
 // ResetRun cancels the current run
 func (rm *RunManager) ResetRun() error {
-	if rm.isRunning {
-		// Save the unfinished run to database
-		if err := rm.saveRun(false); err != nil {
-			return fmt.Errorf("error saving unfinished run: %v", err)
+	return rm.ResetRunWithReason("")
+}
+
+// ResetRunWithReason cancels the current run, storing reason in the notes of
+// the saved unfinished run. A run reset before its first split is not saved
+// and doesn't count as an attempt.
+func (rm *RunManager) ResetRunWithReason(reason string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.resetRun(reason)
+}
+
+func (rm *RunManager) resetRun(reason string) error {
+	// Save the unfinished run to database, unless it was reset before the
+	// first split: that was most likely an accidental start, and shouldn't
+	// count as an attempt
+	if rm.isRunning && len(rm.splits) > 0 {
+		if err := rm.saveRun(false, reason); err != nil {
+			return fmt.Errorf("error saving unfinished run: %w", err)
 		}
 	}
 
 	// Reset everything
 	rm.isRunning = false
 	rm.currentSplit = 0
-	rm.splits = make([]time.Duration, 0, len(rm.splitNames))
+	rm.splits = make([]time.Duration, 0, rm.numSplits)
+	rm.splitStarts = nil
 	rm.isCompleted = false
+	rm.clearPause()
 
 	return nil
 }
 
+// LastSaveWasPB returns whether the most recently saved run became the PB
+func (rm *RunManager) LastSaveWasPB() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.lastSaveWasPB
+}
+
 // GetCurrentTime returns the elapsed time of the current run
 func (rm *RunManager) GetCurrentTime() time.Duration {
-	if !rm.isRunning && len(rm.splits) == 0 {
-		return 0
-	} else if rm.isCompleted {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.currentTime()
+}
+
+// currentTime is the sum of the splits once the run is completed, the time
+// since the start while running, and 0 in every other state: before the
+// first run, after a reset, or for a RunManager that was never loaded.
+func (rm *RunManager) currentTime() time.Duration {
+	switch {
+	case rm.isCompleted:
 		var total time.Duration
 		for _, split := range rm.splits {
 			total += split
 		}
 		return total
-	} else if rm.isRunning {
-		return time.Since(rm.startTime)
+	case rm.isRunning && !rm.startTime.IsZero():
+		return rm.now().Sub(rm.startTime) - rm.pausedTotal
+	default:
+		return 0
 	}
-	return 0
 }
 
 // GetCurrentSplitTime returns the elapsed time of the current split
 func (rm *RunManager) GetCurrentSplitTime() time.Duration {
-	if !rm.isRunning || rm.currentSplit >= len(rm.splitNames) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.currentSplitTime()
+}
+
+func (rm *RunManager) currentSplitTime() time.Duration {
+	if !rm.isRunning || rm.currentSplit >= rm.numSplits || rm.splitStartTime.IsZero() {
 		return 0
 	}
-	return time.Since(rm.splitStartTime)
+	return rm.now().Sub(rm.splitStartTime) - rm.splitPaused
+}
+
+// SegmentComparable reports whether splits[i] is a real single-segment time
+// that can be compared against golds. A zero duration marks a skipped split:
+// its time carries over into the next split, so neither the skipped split nor
+// the one right after it stands for a single segment. Negative durations can
+// only come from corrupt data and are never comparable either.
+func SegmentComparable(splits []time.Duration, i int) bool {
+	if i < 0 || i >= len(splits) || splits[i] <= 0 {
+		return false
+	}
+	return i == 0 || splits[i-1] != 0
+}
+
+// pbEligible reports whether a run with these segments may become the PB.
+// A run with a skipped split may not, even if it is faster.
+func pbEligible(splits []time.Duration) bool {
+	for _, split := range splits {
+		if split == 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // =====================
 // NEW: Best Segments (Gold Splits)
 // =====================
 
-// ComputeBestSegments looks at *all* completed runs and finds the minimum segment
-// time for each split index. It stores that "gold" time in rm.pb.Splits[i].BestSegment.
-// If you want to store gold times in the DB, you'd need a new table or column. Here, we
-// do it purely in memory for display.
+// ComputeBestSegments looks at all completed runs of the current category and
+// finds the minimum segment time for each split index. It stores that "gold" time in rm.goldSegments and,
+// if there is a PB, in rm.pb.Splits[i].BestSegment.
+// The result is saved to the best_segments table, which is loaded at startup
+// and kept up to date by saveRun, so a full rescan is only needed when runs
+// are added or hidden in bulk.
 func (rm *RunManager) ComputeBestSegments() error {
-	if rm.pb == nil || len(rm.pb.Splits) == 0 {
-		// no PB or no splits
-		return nil
-	}
-	numSplits := len(rm.splitNames)
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.computeBestSegments()
+}
+
+func (rm *RunManager) computeBestSegments() error {
+	numSplits := rm.numSplits
 	bestSegments := make([]time.Duration, numSplits)
 	// Initialize them to a large value
 	for i := 0; i < numSplits; i++ {
-		bestSegments[i] = time.Duration(1<<63 - 1) // big
+		bestSegments[i] = noGold
 	}
 
-	// Query all completed runs + their splits
+	// Query the category's completed runs + their splits, in order within
+	// each run so skipped splits can be detected
 	rows, err := rm.db.Query(`
-		SELECT splits.split_index, splits.duration_ns
+		SELECT splits.run_id, splits.split_index, splits.duration_ns
 		FROM splits
 		JOIN runs ON splits.run_id = runs.id
-		WHERE runs.completed = 1
-	`)
+		WHERE runs.completed = 1 AND runs.category = ? AND (? OR runs.archived = 0)
+		ORDER BY splits.run_id, splits.split_index
+	`, rm.category, sqlite3Bool(rm.includeArchived))
 	if err != nil {
-		return fmt.Errorf("ComputeBestSegments: %v", err)
+		return fmt.Errorf("ComputeBestSegments: %w", err)
 	}
 	defer rows.Close()
 
+	var prevRunID int64 = -1
+	var prevDur time.Duration
 	for rows.Next() {
+		var runID int64
 		var idx int
 		var durNs int64
-		if err := rows.Scan(&idx, &durNs); err != nil {
-			return fmt.Errorf("ComputeBestSegments scan: %v", err)
+		if err := rows.Scan(&runID, &idx, &durNs); err != nil {
+			return fmt.Errorf("ComputeBestSegments scan: %w", err)
 		}
 		d := time.Duration(durNs)
+		// A segment right after a skipped split also covers the skipped one
+		afterSkip := runID == prevRunID && prevDur == 0
+		prevRunID, prevDur = runID, d
+		// Zero and negative segments would make golds that can't be beaten.
+		// They are filtered here rather than in the query, which has to
+		// return the zeros to find the segments after them.
+		if d <= 0 || afterSkip {
+			continue
+		}
 		if idx >= 0 && idx < numSplits && d < bestSegments[idx] {
 			bestSegments[idx] = d
 		}
@@ -307,12 +992,44 @@ func (rm *RunManager) ComputeBestSegments() error {
 		return err
 	}
 
+	// Splits that have no usable segment yet get no gold
+	for i := range bestSegments {
+		if bestSegments[i] == noGold {
+			bestSegments[i] = 0
+		}
+	}
+	rm.goldSegments = bestSegments
+
 	// Now fill in the PB run's BestSegment field
-	for i := range rm.pb.Splits {
-		rm.pb.Splits[i].BestSegment = bestSegments[i]
+	rm.applyGoldsToPB()
+
+	// The average and median change with the same runs as the golds
+	if err := rm.refreshStatComparisons(); err != nil {
+		return err
 	}
 
-	return nil
+	// The stored golds never count archived runs
+	if rm.includeArchived {
+		return nil
+	}
+	return rm.storeBestSegments()
+}
+
+// PBSegments returns a copy of the PB's splits with IsGold set for segments
+// that match the all-time best. Returns nil if there is no PB.
+func (rm *RunManager) PBSegments() []Split {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	if rm.pb == nil {
+		return nil
+	}
+	segments := make([]Split, len(rm.pb.Splits))
+	for i, split := range rm.pb.Splits {
+		segments[i] = split
+		segments[i].AbsoluteTime = rm.pb.TimeSince(i)
+		segments[i].IsGold = split.BestSegment > 0 && split.Duration <= split.BestSegment
+	}
+	return segments
 }
 
 // =====================
@@ -323,10 +1040,19 @@ func (rm *RunManager) ComputeBestSegments() error {
 // than the stored PB. If there is no PB, it returns true if the current run
 // is completed, false otherwise.
 func (rm *RunManager) IsBetterThanPB() bool {
-	if !rm.isCompleted {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.isBetterThanPB()
+}
+
+func (rm *RunManager) isBetterThanPB() bool {
+	if !rm.isCompleted || rm.practice {
 		// not finished
 		return false
 	}
+	if !pbEligible(rm.splits) {
+		return false
+	}
 	var currentTotal time.Duration
 	for _, seg := range rm.splits {
 		currentTotal += seg
@@ -335,24 +1061,26 @@ func (rm *RunManager) IsBetterThanPB() bool {
 		// no PB in DB, so if we completed, it's automatically "better"
 		return true
 	}
-	var pbTotal time.Duration
-	for _, seg := range rm.pb.Splits {
-		pbTotal += seg.Duration
-	}
-	return currentTotal < pbTotal
+	return currentTotal < rm.pb.PBTime
 }
 
 // SaveAsPB forces the last completed run to become PB, even if it's slower.
 // Typically you'd only call this if IsBetterThanPB() is true, but you can do
 // it unconditionally if you want to override your PB.
 func (rm *RunManager) SaveAsPB() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.saveAsPB()
+}
+
+func (rm *RunManager) saveAsPB() error {
 	if !rm.isCompleted {
 		return fmt.Errorf("cannot save as PB: run not completed")
 	}
-	// We'll assume the last run we saved is the one we want to set as PB.
-	// That means we need to find that run's ID in the DB. If you want to
-	// store it in a field, you can. For simplicity, let's just take the
-	// largest run_id for which completed=1.
+	// The completed run was saved by saveRun, which remembered its ID
+	if rm.currentRunID == 0 {
+		return fmt.Errorf("cannot save as PB: run not saved")
+	}
 	tx, err := rm.db.Begin()
 	if err != nil {
 		return err
@@ -360,26 +1088,17 @@ func (rm *RunManager) SaveAsPB() error {
 	defer tx.Rollback()
 
 	// Unset old PB
-	if _, err = tx.Exec(`UPDATE runs SET is_pb = 0 WHERE is_pb = 1`); err != nil {
-		return fmt.Errorf("error resetting old PB: %v", err)
-	}
-
-	// Find the latest completed run
-	row := tx.QueryRow(`
-		SELECT id 
-		FROM runs
-		WHERE completed = 1
-		ORDER BY id DESC
-		LIMIT 1
-	`)
-	var lastCompletedID int64
-	if err := row.Scan(&lastCompletedID); err != nil {
-		return fmt.Errorf("error finding last completed run: %v", err)
+	if _, err = tx.Exec(`UPDATE runs SET is_pb = 0 WHERE is_pb = 1 AND category = ?`, rm.category); err != nil {
+		return fmt.Errorf("error resetting old PB: %w", err)
 	}
 
 	// Mark it as PB
-	if _, err := tx.Exec(`UPDATE runs SET is_pb = 1 WHERE id = ?`, lastCompletedID); err != nil {
-		return fmt.Errorf("error setting new PB: %v", err)
+	var total time.Duration
+	for _, split := range rm.splits {
+		total += split
+	}
+	if _, err := tx.Exec(`UPDATE runs SET is_pb = 1, pb_time_ns = ? WHERE id = ?`, total.Nanoseconds(), rm.currentRunID); err != nil {
+		return fmt.Errorf("error setting new PB: %w", err)
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -387,16 +1106,14 @@ func (rm *RunManager) SaveAsPB() error {
 	}
 
 	// Reload PB so rm.pb is up to date
-	newPB, err := loadPersonalBest(rm.db)
+	newPB, err := loadPersonalBest(rm.db, rm.category)
 	if err != nil {
-		return fmt.Errorf("error reloading PB: %v", err)
+		return fmt.Errorf("error reloading PB: %w", err)
 	}
 	rm.pb = newPB
 
-	// Also re-compute gold splits if you want them updated
-	if err := rm.ComputeBestSegments(); err != nil {
-		log.Printf("Warning: Could not re-compute best segments after SaveAsPB: %v", err)
-	}
+	// The golds don't change, but the reloaded PB needs them filled in
+	rm.applyGoldsToPB()
 
 	return nil
 }
@@ -405,6 +1122,16 @@ func (rm *RunManager) SaveAsPB() error {
 // Private / existing code
 // =====================
 
+// copyRun returns a deep copy of r so callers can't mutate internal state
+func copyRun(r *Run) *Run {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	c.Splits = append([]Split(nil), r.Splits...)
+	return &c
+}
+
 func sqlite3Bool(b bool) int {
 	if b {
 		return 1
@@ -423,11 +1150,15 @@ func initDatabase(db *sql.DB) error {
 			end_time TIMESTAMP,
 			completed BOOLEAN NOT NULL DEFAULT 0,
 			is_pb BOOLEAN NOT NULL DEFAULT 0,
-			attempt_num INTEGER NOT NULL
+			attempt_num INTEGER NOT NULL,
+			notes TEXT,
+			archived BOOLEAN DEFAULT 0,
+			pb_time_ns INTEGER,
+			game_version TEXT
 		)
 	`)
 	if err != nil {
-		return fmt.Errorf("error creating runs table: %v", err)
+		return fmt.Errorf("error creating runs table: %w", err)
 	}
 
 	// Create splits table
@@ -438,11 +1169,24 @@ func initDatabase(db *sql.DB) error {
 			split_index INTEGER NOT NULL,
 			split_name TEXT NOT NULL,
 			duration_ns INTEGER NOT NULL,
+			note TEXT,
 			FOREIGN KEY (run_id) REFERENCES runs(id)
 		)
 	`)
 	if err != nil {
-		return fmt.Errorf("error creating splits table: %v", err)
+		return fmt.Errorf("error creating splits table: %w", err)
+	}
+
+	// Create best segments table, holding the gold time of each split
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS best_segments (
+			split_index INTEGER PRIMARY KEY,
+			split_name TEXT,
+			duration_ns INTEGER
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating best_segments table: %w", err)
 	}
 
 	// Create config table
@@ -456,7 +1200,7 @@ func initDatabase(db *sql.DB) error {
 		)
 	`)
 	if err != nil {
-		return fmt.Errorf("error creating config table: %v", err)
+		return fmt.Errorf("error creating config table: %w", err)
 	}
 
 	// Create split_names table
@@ -464,14 +1208,26 @@ func initDatabase(db *sql.DB) error {
 		CREATE TABLE IF NOT EXISTS split_names (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL,
-			display_order INTEGER NOT NULL
+			display_order INTEGER NOT NULL,
+			subsplit_depth INTEGER DEFAULT 0
 		)
 	`)
 	if err != nil {
-		return fmt.Errorf("error creating split_names table: %v", err)
+		return fmt.Errorf("error creating split_names table: %w", err)
 	}
 
-	return nil
+	// Create schema_version table, filled in by schemaVersion
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			version INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_version table: %w", err)
+	}
+
+	return initKeybinds(db)
 }
 
 func loadConfig(db *sql.DB) (string, string, int, int, []string, error) {
@@ -491,17 +1247,17 @@ func loadConfig(db *sql.DB) (string, string, int, int, []string, error) {
 			_, err = db.Exec("INSERT INTO config (id, title, category, attempts, completed) VALUES (1, ?, ?, ?, ?)",
 				title, category, attempts, completed)
 			if err != nil {
-				return "", "", 0, 0, nil, fmt.Errorf("error creating default config: %v", err)
+				return "", "", 0, 0, nil, fmt.Errorf("error creating default config: %w", err)
 			}
 		} else {
-			return "", "", 0, 0, nil, fmt.Errorf("error loading config: %v", err)
+			return "", "", 0, 0, nil, fmt.Errorf("error loading config: %w", err)
 		}
 	}
 
 	// Get split names
 	rows, err := db.Query("SELECT name FROM split_names ORDER BY display_order")
 	if err != nil {
-		return "", "", 0, 0, nil, fmt.Errorf("error loading split names: %v", err)
+		return "", "", 0, 0, nil, fmt.Errorf("error loading split names: %w", err)
 	}
 	defer rows.Close()
 
@@ -509,7 +1265,7 @@ func loadConfig(db *sql.DB) (string, string, int, int, []string, error) {
 	for rows.Next() {
 		var name string
 		if err := rows.Scan(&name); err != nil {
-			return "", "", 0, 0, nil, fmt.Errorf("error scanning split name: %v", err)
+			return "", "", 0, 0, nil, fmt.Errorf("error scanning split name: %w", err)
 		}
 		splitNames = append(splitNames, name)
 	}
@@ -520,7 +1276,7 @@ func loadConfig(db *sql.DB) (string, string, int, int, []string, error) {
 		for i, name := range defaultSplits {
 			_, err = db.Exec("INSERT INTO split_names (name, display_order) VALUES (?, ?)", name, i)
 			if err != nil {
-				return "", "", 0, 0, nil, fmt.Errorf("error creating default splits: %v", err)
+				return "", "", 0, 0, nil, fmt.Errorf("error creating default splits: %w", err)
 			}
 		}
 		splitNames = defaultSplits
@@ -529,26 +1285,51 @@ func loadConfig(db *sql.DB) (string, string, int, int, []string, error) {
 	return title, category, attempts, completed, splitNames, nil
 }
 
-func loadPersonalBest(db *sql.DB) (*Run, error) {
+func loadSplitDepths(db *sql.DB) ([]int, error) {
+	rows, err := db.Query("SELECT COALESCE(subsplit_depth, 0) FROM split_names ORDER BY display_order")
+	if err != nil {
+		return nil, fmt.Errorf("error loading split depths: %w", err)
+	}
+	defer rows.Close()
+
+	var depths []int
+	for rows.Next() {
+		var depth int
+		if err := rows.Scan(&depth); err != nil {
+			return nil, fmt.Errorf("error scanning split depth: %w", err)
+		}
+		depths = append(depths, depth)
+	}
+	return depths, rows.Err()
+}
+
+// GetPersonalBestForCategory returns the PB run of category, or nil if it has
+// none. Its BestSegment fields are not filled in.
+func (rm *RunManager) GetPersonalBestForCategory(category string) (*Run, error) {
+	return loadPersonalBest(rm.db, category)
+}
+
+func loadPersonalBest(db *sql.DB, category string) (*Run, error) {
 	// Get the personal best run
 	row := db.QueryRow(`
-		SELECT id, title, category, start_time, end_time, completed, is_pb, attempt_num
+		SELECT id, title, category, start_time, end_time, completed, is_pb, attempt_num, pb_time_ns
 		FROM runs
-		WHERE is_pb = 1 AND completed = 1
+		WHERE is_pb = 1 AND completed = 1 AND category = ?
 		LIMIT 1
-	`)
+	`, category)
 
 	var pb Run
 	var startTimeStr, endTimeStr string
+	var pbTimeNs sql.NullInt64
 	err := row.Scan(
 		&pb.ID, &pb.Title, &pb.Category, &startTimeStr, &endTimeStr,
-		&pb.Completed, &pb.IsPB, &pb.AttemptNum,
+		&pb.Completed, &pb.IsPB, &pb.AttemptNum, &pbTimeNs,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No PB yet
 		}
-		return nil, fmt.Errorf("error loading personal best: %v", err)
+		return nil, fmt.Errorf("error loading personal best: %w", err)
 	}
 
 	// Parse timestamps
@@ -563,7 +1344,7 @@ func loadPersonalBest(db *sql.DB) (*Run, error) {
 		ORDER BY split_index
 	`, pb.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error loading PB splits: %v", err)
+		return nil, fmt.Errorf("error loading PB splits: %w", err)
 	}
 	defer rows.Close()
 
@@ -571,25 +1352,35 @@ func loadPersonalBest(db *sql.DB) (*Run, error) {
 		var splitName string
 		var durationNs int64
 		if err := rows.Scan(&splitName, &durationNs); err != nil {
-			return nil, fmt.Errorf("error scanning split data: %v", err)
+			return nil, fmt.Errorf("error scanning split data: %w", err)
 		}
-		pb.Splits = append(pb.Splits, Split{
-			Name:     splitName,
-			Duration: time.Duration(durationNs),
-		})
+		pb.appendSplit(splitName, time.Duration(durationNs))
+	}
+
+	// PBs saved before pb_time_ns existed don't have it stored
+	if pbTimeNs.Valid {
+		pb.PBTime = time.Duration(pbTimeNs.Int64)
+	} else {
+		pb.PBTime = pb.TimeSince(len(pb.Splits) - 1)
 	}
 
 	return &pb, nil
 }
 
-func (rm *RunManager) saveRun(completed bool) error {
+func (rm *RunManager) saveRun(completed bool, notes string) error {
+	if rm.practice {
+		rm.currentRunID = 0
+		rm.lastSaveWasPB = false
+		return nil
+	}
+
 	// Calculate end time
 	endTime := time.Now()
 
 	// Start transaction
 	tx, err := rm.db.Begin()
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -603,31 +1394,32 @@ func (rm *RunManager) saveRun(completed bool) error {
 	_, err = tx.Exec("UPDATE config SET attempts = ?, completed = ? WHERE id = 1",
 		rm.attempts, rm.completedRuns)
 	if err != nil {
-		return fmt.Errorf("error updating config: %v", err)
+		return fmt.Errorf("error updating config: %w", err)
 	}
 
 	// Insert new run
 	result, err := tx.Exec(`
 		INSERT INTO runs 
-		(title, category, start_time, end_time, completed, is_pb, attempt_num)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		(title, category, start_time, end_time, completed, is_pb, attempt_num, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		rm.title, rm.category, rm.startTime.Format(time.RFC3339),
 		endTime.Format(time.RFC3339),
 		sqlite3Bool(completed), sqlite3Bool(false), rm.attempts,
+		sql.NullString{String: notes, Valid: notes != ""},
 	)
 	if err != nil {
-		return fmt.Errorf("error inserting run: %v", err)
+		return fmt.Errorf("error inserting run: %w", err)
 	}
 
 	runID, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("error getting last insert ID: %v", err)
+		return fmt.Errorf("error getting last insert ID: %w", err)
 	}
 
 	// Check if this is a new personal best (by total time)
 	isPB := false
-	if completed {
+	if completed && pbEligible(rm.splits) {
 		var totalTime time.Duration
 		for _, split := range rm.splits {
 			totalTime += split
@@ -637,24 +1429,20 @@ func (rm *RunManager) saveRun(completed bool) error {
 		if rm.pb == nil {
 			isPB = true
 		} else {
-			var pbTotalTime time.Duration
-			for _, split := range rm.pb.Splits {
-				pbTotalTime += split.Duration
-			}
-			isPB = totalTime < pbTotalTime
+			isPB = totalTime < rm.pb.PBTime
 		}
 
 		if isPB {
 			// Reset previous PB flag if exists
-			_, err = tx.Exec("UPDATE runs SET is_pb = 0 WHERE is_pb = 1")
+			_, err = tx.Exec("UPDATE runs SET is_pb = 0 WHERE is_pb = 1 AND category = ?", rm.category)
 			if err != nil {
-				return fmt.Errorf("error resetting previous PB: %v", err)
+				return fmt.Errorf("error resetting previous PB: %w", err)
 			}
 
 			// Set this run as PB
-			_, err = tx.Exec("UPDATE runs SET is_pb = 1 WHERE id = ?", runID)
+			_, err = tx.Exec("UPDATE runs SET is_pb = 1, pb_time_ns = ? WHERE id = ?", totalTime.Nanoseconds(), runID)
 			if err != nil {
-				return fmt.Errorf("error setting new PB: %v", err)
+				return fmt.Errorf("error setting new PB: %w", err)
 			}
 		}
 	}
@@ -666,68 +1454,152 @@ func (rm *RunManager) saveRun(completed bool) error {
 			VALUES (?, ?, ?, ?)
 		`, runID, i, rm.splitNames[i], split.Nanoseconds())
 		if err != nil {
-			return fmt.Errorf("error inserting split: %v", err)
+			return fmt.Errorf("error inserting split: %w", err)
+		}
+	}
+
+	// Any completed run may hold new golds, PB or not
+	golds := rm.goldSegments
+	if completed {
+		golds, err = rm.mergeGolds(tx, rm.splits, func(i int) bool { return SegmentComparable(rm.splits, i) })
+		if err != nil {
+			return err
 		}
 	}
 
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
+	rm.currentRunID = runID
+	rm.lastSaveWasPB = isPB
+	rm.goldSegments = golds
+
 	// If this was a PB, reload it
 	if isPB {
-		rm.pb, err = loadPersonalBest(rm.db)
+		rm.pb, err = loadPersonalBest(rm.db, rm.category)
 		if err != nil {
 			log.Printf("Warning: Failed to reload PB: %v", err)
-		} else {
-			// Recompute gold splits so rm.pb.Splits[i].BestSegment is up to date
-			if err := rm.ComputeBestSegments(); err != nil {
-				log.Printf("Warning: Could not compute best segments: %v", err)
-			}
+		}
+	}
+
+	rm.applyGoldsToPB()
+
+	if completed {
+		if err := rm.refreshStatComparisons(); err != nil {
+			log.Printf("Warning: Failed to update average and median: %v", err)
 		}
 	}
 
 	return nil
 }
 
-// UpdateSplitNames replaces the current split names with a new set
+// UpdateSplitNames is the old name of SetSplitNames.
+//
+// Deprecated: use SetSplitNames.
 func (rm *RunManager) UpdateSplitNames(names []string) error {
+	return rm.SetSplitNames(names)
+}
+
+// SetSplitNames replaces the current split names with a new set, all at the
+// top level. It returns ErrRunInProgress while a run is going, since the
+// splits recorded so far belong to the old names.
+func (rm *RunManager) SetSplitNames(names []string) error {
+	nodes := make([]SplitNode, len(names))
+	for i, name := range names {
+		nodes[i] = SplitNode{Name: name}
+	}
+	return rm.SetSplitNodes(nodes)
+}
+
+// SetSplitNodes is SetSplitNames with a sub-split depth for each split. The
+// golds are recomputed for the new splits.
+func (rm *RunManager) SetSplitNodes(nodes []SplitNode) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.isRunning {
+		return ErrRunInProgress
+	}
 	tx, err := rm.db.Begin()
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback()
 
 	_, err = tx.Exec("DELETE FROM split_names")
 	if err != nil {
-		return fmt.Errorf("error deleting existing split names: %v", err)
+		return fmt.Errorf("error deleting existing split names: %w", err)
 	}
 
-	for i, name := range names {
-		_, err = tx.Exec("INSERT INTO split_names (name, display_order) VALUES (?, ?)", name, i)
+	names := make([]string, len(nodes))
+	depths := make([]int, len(nodes))
+	for i, node := range nodes {
+		_, err = tx.Exec("INSERT INTO split_names (name, display_order, subsplit_depth) VALUES (?, ?, ?)",
+			node.Name, i, node.Depth)
 		if err != nil {
-			return fmt.Errorf("error inserting split name: %v", err)
+			return fmt.Errorf("error inserting split name: %w", err)
 		}
+		names[i], depths[i] = node.Name, node.Depth
+	}
+
+	// Stored golds are keyed by the old split indices
+	if _, err := tx.Exec("DELETE FROM best_segments"); err != nil {
+		return fmt.Errorf("error clearing best segments: %w", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
-	rm.splitNames = names
-	return nil
+	rm.setSplitNames(names, depths)
+	return rm.computeBestSegments()
+}
+
+// setSplitNames replaces the in-memory split list. Missing depths default to 0.
+// The names are copied, so the caller's slice can be reused. Per-split times
+// are cut or padded with zeros to the new length.
+func (rm *RunManager) setSplitNames(names []string, depths []int) {
+	rm.splitNames = append([]string(nil), names...)
+	rm.splitDepths = make([]int, len(names))
+	copy(rm.splitDepths, depths)
+	rm.numSplits = len(names)
+	rm.goldSegments = resizeSegments(rm.goldSegments, len(names))
+	rm.averageSegments = resizeSegments(rm.averageSegments, len(names))
+	rm.medianSegments = resizeSegments(rm.medianSegments, len(names))
+}
+
+// resizeSegments returns a copy of segments with n entries
+func resizeSegments(segments []time.Duration, n int) []time.Duration {
+	resized := make([]time.Duration, n)
+	copy(resized, segments)
+	return resized
 }
 
-// UpdateConfig changes the run title/category in the DB and updates memory
+// UpdateConfig is the old name of SetConfig.
+//
+// Deprecated: use SetConfig.
 func (rm *RunManager) UpdateConfig(title, category string) error {
+	return rm.SetConfig(title, category)
+}
+
+// SetConfig changes the run title/category in the DB and updates memory
+func (rm *RunManager) SetConfig(title, category string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 	_, err := rm.db.Exec("UPDATE config SET title = ?, category = ? WHERE id = 1",
 		title, category)
 	if err != nil {
-		return fmt.Errorf("error updating config: %v", err)
+		return fmt.Errorf("error updating config: %w", err)
 	}
 
 	rm.title = title
 	rm.category = category
-	return nil
+
+	// Every category has its own PB and golds
+	rm.pb, err = loadPersonalBest(rm.db, category)
+	if err != nil {
+		return fmt.Errorf("failed to load PB for %s: %w", category, err)
+	}
+	return rm.computeBestSegments()
 }
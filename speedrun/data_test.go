@@ -0,0 +1,498 @@
+package speedrun
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRunManager opens a RunManager on a new database in a temp dir. If
+// names are given, they replace the default split names.
+func newTestRunManager(t *testing.T, names ...string) *RunManager {
+	t.Helper()
+	return openTestRunManager(t, filepath.Join(t.TempDir(), "test.db"), names...)
+}
+
+// openTestRunManager is newTestRunManager for a database at path
+func openTestRunManager(t *testing.T, path string, names ...string) *RunManager {
+	t.Helper()
+	rm, err := NewRunManager(path)
+	if err != nil {
+		t.Fatalf("NewRunManager: %v", err)
+	}
+	t.Cleanup(func() { rm.Close() })
+	if len(names) > 0 {
+		if err := rm.SetSplitNames(names); err != nil {
+			t.Fatalf("SetSplitNames: %v", err)
+		}
+	}
+	return rm
+}
+
+// recordRun saves a run with the given segment times, as if it had just been
+// run, and resets. A run with fewer segments than splits is saved as reset.
+func recordRun(t *testing.T, rm *RunManager, segments ...time.Duration) {
+	t.Helper()
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.startRun()
+	rm.splits = append(rm.splits, segments...)
+	if err := rm.saveRun(len(segments) == rm.numSplits, ""); err != nil {
+		t.Fatalf("saveRun: %v", err)
+	}
+	rm.isRunning = false
+	if err := rm.resetRun(""); err != nil {
+		t.Fatalf("resetRun: %v", err)
+	}
+}
+
+func TestCurrentSplitNameFollowsRun(t *testing.T) {
+	rm := newTestRunManager(t, "Forest", "Castle", "Boss")
+	if name := rm.GetCurrentSplitName(); name != "Forest" {
+		t.Errorf("before the run, current split = %q, want Forest", name)
+	}
+
+	rm.StartRun()
+	for _, want := range []string{"Forest", "Castle", "Boss"} {
+		if name := rm.GetCurrentSplitName(); name != want {
+			t.Errorf("current split = %q, want %q", name, want)
+		}
+		state := rm.GetStreamingState()
+		if name, _ := rm.GetSplitNameByIndex(state.CurrentSplit); name != want || state.CurrentSplitName != want {
+			t.Errorf("streaming state current split = %d %q, want %q", state.CurrentSplit, state.CurrentSplitName, want)
+		}
+		if _, err := rm.Split(); err != nil {
+			t.Fatalf("Split: %v", err)
+		}
+	}
+
+	if _, err := rm.GetSplitNameByIndex(3); err != ErrIndexOutOfRange {
+		t.Errorf("GetSplitNameByIndex(3) error = %v, want ErrIndexOutOfRange", err)
+	}
+}
+
+func TestNewRunManagerCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.db")
+	rm, err := NewRunManagerWithOptions(path, DefaultNewRunManagerOptions)
+	if err != nil {
+		t.Fatalf("NewRunManagerWithOptions: %v", err)
+	}
+	rm.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database was not created: %v", err)
+	}
+}
+
+func TestNewRunManagerNoCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.db")
+	opts := DefaultNewRunManagerOptions
+	opts.NoCreate = true
+	if _, err := NewRunManagerWithOptions(path, opts); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Errorf("NewRunManagerWithOptions error = %v, want ErrDatabaseNotFound", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("database was created anyway: %v", err)
+	}
+
+	// An existing database opens as usual
+	openTestRunManager(t, path).Close()
+	rm, err := NewRunManagerWithOptions(path, opts)
+	if err != nil {
+		t.Fatalf("NewRunManagerWithOptions on an existing database: %v", err)
+	}
+	rm.Close()
+}
+
+func TestPBSegments(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B", "C")
+	if segments := rm.PBSegments(); segments != nil {
+		t.Errorf("PBSegments without a PB = %v, want nil", segments)
+	}
+
+	recordRun(t, rm, 10*time.Second, 20*time.Second, 30*time.Second)
+	// Slower overall, but with a gold on B
+	recordRun(t, rm, 12*time.Second, 15*time.Second, 40*time.Second)
+
+	segments := rm.PBSegments()
+	want := []struct {
+		name         string
+		duration     time.Duration
+		absoluteTime time.Duration
+		isGold       bool
+	}{
+		{"A", 10 * time.Second, 10 * time.Second, true},
+		{"B", 20 * time.Second, 30 * time.Second, false},
+		{"C", 30 * time.Second, 60 * time.Second, true},
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("got %d segments, want %d", len(segments), len(want))
+	}
+	for i, w := range want {
+		s := segments[i]
+		if s.Name != w.name || s.Duration != w.duration || s.AbsoluteTime != w.absoluteTime || s.IsGold != w.isGold {
+			t.Errorf("segment %d = %s %v %v gold=%v, want %s %v %v gold=%v", i,
+				s.Name, s.Duration, s.AbsoluteTime, s.IsGold, w.name, w.duration, w.absoluteTime, w.isGold)
+		}
+	}
+	if gold := segments[1].BestSegment; gold != 15*time.Second {
+		t.Errorf("B's best segment = %v, want 15s", gold)
+	}
+}
+
+// pressSplit calls HandleSplitKey and checks the action it took
+func pressSplit(t *testing.T, rm *RunManager, want SplitKeyAction) {
+	t.Helper()
+	action, err := rm.HandleSplitKey()
+	if err != nil {
+		t.Fatalf("HandleSplitKey: %v", err)
+	}
+	if action != want {
+		t.Fatalf("HandleSplitKey = %v, want %v", action, want)
+	}
+}
+
+func TestHandleSplitKeyCycle(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+
+	pressSplit(t, rm, SplitKeyStarted)
+	pressSplit(t, rm, SplitKeySplit)
+	pressSplit(t, rm, SplitKeyFinished)
+	if !rm.IsCompleted() || rm.GetAttempts() != 1 {
+		t.Fatalf("after finishing: completed %v, attempts %d", rm.IsCompleted(), rm.GetAttempts())
+	}
+
+	// Without auto-start, a press after finishing does nothing
+	pressSplit(t, rm, SplitKeyIgnored)
+	if !rm.IsCompleted() || rm.IsRunning() {
+		t.Fatal("a press after finishing changed the run")
+	}
+
+	if err := rm.ResetRun(); err != nil {
+		t.Fatalf("ResetRun: %v", err)
+	}
+	pressSplit(t, rm, SplitKeyStarted)
+	if len(rm.GetCurrentSplits()) != 0 || rm.GetCurrentSplit() != 0 {
+		t.Fatal("the new run didn't start fresh")
+	}
+	pressSplit(t, rm, SplitKeySplit)
+	if got := len(rm.GetCurrentSplits()); got != 1 {
+		t.Errorf("got %d splits, want 1", got)
+	}
+	if got := rm.GetAttempts(); got != 1 {
+		t.Errorf("attempts = %d, want 1: the finished run was saved twice", got)
+	}
+}
+
+func TestHandleSplitKeyAutoStart(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	rm.SetAutoStart(true)
+
+	pressSplit(t, rm, SplitKeyStarted)
+	pressSplit(t, rm, SplitKeySplit)
+	pressSplit(t, rm, SplitKeyFinished)
+
+	// The press after finishing resets and starts a fresh run
+	pressSplit(t, rm, SplitKeyStarted)
+	if !rm.IsRunning() || rm.IsCompleted() || len(rm.GetCurrentSplits()) != 0 {
+		t.Fatal("the press after finishing didn't start a fresh run")
+	}
+	pressSplit(t, rm, SplitKeySplit)
+	if got := rm.GetAttempts(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+
+	if err := rm.PauseRun(); err != nil {
+		t.Fatalf("PauseRun: %v", err)
+	}
+	if action, err := rm.HandleSplitKey(); action != SplitKeyIgnored || !errors.Is(err, ErrPaused) {
+		t.Errorf("HandleSplitKey while paused = %v, %v, want ignored with ErrPaused", action, err)
+	}
+}
+
+func TestSetSplitNamesCopies(t *testing.T) {
+	rm := newTestRunManager(t)
+	names := []string{"A", "B", "C"}
+	if err := rm.SetSplitNames(names); err != nil {
+		t.Fatalf("SetSplitNames: %v", err)
+	}
+	names[0] = "changed"
+	if got := rm.GetSplitNames(); got[0] != "A" {
+		t.Errorf("split names = %q, changed through the caller's slice", got)
+	}
+	if got := len(rm.GetGoldSegments()); got != 3 {
+		t.Errorf("got %d golds for 3 splits", got)
+	}
+}
+
+func TestAbsoluteTime(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B", "C")
+	recordRun(t, rm, 10*time.Second, 0, 30*time.Second)
+
+	runs, err := rm.ListRuns(1, 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListRuns = %d runs, %v", len(runs), err)
+	}
+	want := []time.Duration{10 * time.Second, 10 * time.Second, 40 * time.Second}
+	for i, w := range want {
+		if got := runs[0].Splits[i].AbsoluteTime; got != w {
+			t.Errorf("split %d AbsoluteTime = %v, want %v", i, got, w)
+		}
+		if got := runs[0].TimeSince(i); got != w {
+			t.Errorf("TimeSince(%d) = %v, want %v", i, got, w)
+		}
+	}
+	if got := rm.GetStreamingState().AbsoluteTimes; len(got) != 0 {
+		t.Errorf("AbsoluteTimes before the run = %v, want none", got)
+	}
+
+	// A Run built by hand has no AbsoluteTime, so the durations are summed
+	run := Run{Splits: []Split{{Duration: time.Second}, {Duration: 2 * time.Second}}}
+	if got := run.TimeSince(1); got != 3*time.Second {
+		t.Errorf("TimeSince without AbsoluteTime = %v, want 3s", got)
+	}
+	if got := run.TimeSince(2); got != 0 {
+		t.Errorf("TimeSince out of range = %v, want 0", got)
+	}
+}
+
+func TestHandleResetKey(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	pressSplit(t, rm, SplitKeyStarted)
+	pressSplit(t, rm, SplitKeySplit)
+	pressSplit(t, rm, SplitKeyFinished)
+
+	if err := rm.HandleResetKey(); err != nil {
+		t.Fatalf("HandleResetKey: %v", err)
+	}
+	if rm.IsRunning() || rm.IsCompleted() || len(rm.GetCurrentSplits()) != 0 {
+		t.Error("the run wasn't reset")
+	}
+	var runs int
+	if err := rm.db.QueryRow("SELECT COUNT(*) FROM runs").Scan(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 || rm.GetAttempts() != 1 {
+		t.Errorf("got %d runs and %d attempts, want the finished run saved once", runs, rm.GetAttempts())
+	}
+	if pb := rm.GetPersonalBest(); pb == nil || len(pb.Splits) != 2 {
+		t.Errorf("PB = %+v, want the finished run", pb)
+	}
+
+	// A second press with nothing to reset saves nothing
+	if err := rm.HandleResetKey(); err != nil {
+		t.Fatalf("HandleResetKey when idle: %v", err)
+	}
+	if rm.GetAttempts() != 1 {
+		t.Errorf("attempts = %d after resetting an idle timer, want 1", rm.GetAttempts())
+	}
+}
+
+func TestSetSplitNodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	rm := openTestRunManager(t, path)
+	nodes := []SplitNode{{Name: "World 1"}, {Name: "1-1", Depth: 1}, {Name: "1-2", Depth: 1}}
+	if err := rm.SetSplitNodes(nodes); err != nil {
+		t.Fatalf("SetSplitNodes: %v", err)
+	}
+	rm.Close()
+
+	got := openTestRunManager(t, path).GetSplitNamesWithDepth()
+	if !slices.Equal(got, nodes) {
+		t.Errorf("stored splits = %v, want %v", got, nodes)
+	}
+
+	rm = openTestRunManager(t, path)
+	rm.StartRun()
+	if err := rm.SetSplitNodes(nodes[:1]); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("SetSplitNodes during a run = %v, want ErrRunInProgress", err)
+	}
+}
+
+func TestGetPBSegmentDuration(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B", "C")
+	if got := rm.GetPBSegmentDuration(0); got != 0 {
+		t.Errorf("without a PB: %v, want 0", got)
+	}
+	recordRun(t, rm, 10*time.Second, 20*time.Second, 30*time.Second)
+	for i, want := range []time.Duration{0, 10 * time.Second, 20 * time.Second, 30 * time.Second, 0} {
+		if got := rm.GetPBSegmentDuration(i - 1); got != want {
+			t.Errorf("GetPBSegmentDuration(%d) = %v, want %v", i-1, got, want)
+		}
+	}
+}
+
+func TestGetCurrentTimeBeforeStart(t *testing.T) {
+	if got := (&RunManager{}).GetCurrentTime(); got != 0 {
+		t.Errorf("zero RunManager time = %v, want 0", got)
+	}
+
+	rm := newTestRunManager(t, "A", "B")
+	if got := rm.GetCurrentTime(); got != 0 {
+		t.Errorf("fresh RunManager time = %v, want 0", got)
+	}
+	rm.StartRun()
+	if _, err := rm.Split(); err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if err := rm.ResetRun(); err != nil {
+		t.Fatalf("ResetRun: %v", err)
+	}
+	if got := rm.GetCurrentTime(); got != 0 {
+		t.Errorf("time after reset = %v, want 0", got)
+	}
+}
+
+func TestSkippedRunNeverBecomesPB(t *testing.T) {
+	dir := t.TempDir()
+	rm := openTestRunManager(t, filepath.Join(dir, "a.db"), "A", "B")
+
+	// The first completed run is normally the PB, but not with a skip
+	recordRun(t, rm, 10*time.Second, 0)
+	if pb := rm.GetPersonalBest(); pb != nil {
+		t.Fatalf("PB = %+v after a run with a skipped split, want none", pb)
+	}
+
+	recordRun(t, rm, 20*time.Second, 20*time.Second)
+	runs, err := rm.ListRuns(1, 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListRuns = %d runs, %v", len(runs), err)
+	}
+
+	// A faster skipped run doesn't beat it either
+	rm.mu.Lock()
+	rm.startRun()
+	rm.splits = append(rm.splits, 5*time.Second, 0)
+	rm.isRunning = false
+	rm.isCompleted = true
+	better := rm.isBetterThanPB()
+	rm.mu.Unlock()
+	if better {
+		t.Error("isBetterThanPB = true for a run with a skipped split")
+	}
+	if err := rm.ResetRun(); err != nil {
+		t.Fatalf("ResetRun: %v", err)
+	}
+
+	// Nor does it take over when the PB is deleted
+	if err := rm.DeleteRun(runs[0].ID); err != nil {
+		t.Fatalf("DeleteRun: %v", err)
+	}
+	if pb := rm.GetPersonalBest(); pb != nil {
+		t.Errorf("PB = %+v after deleting the only full run, want none", pb)
+	}
+
+	// Or when it is merged in from another database
+	otherPath := filepath.Join(dir, "b.db")
+	other := openTestRunManager(t, otherPath, "A", "B")
+	recordRun(t, other, 1*time.Second, 0)
+	other.Close()
+	if err := rm.MergeDatabase(otherPath); err != nil {
+		t.Fatalf("MergeDatabase: %v", err)
+	}
+	if pb := rm.GetPersonalBest(); pb != nil {
+		t.Errorf("PB = %+v after merging a run with a skipped split, want none", pb)
+	}
+}
+
+func TestGoldsAndStatsPerCategory(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	if err := rm.SetConfig("Game", "Any%"); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	recordRun(t, rm, 10*time.Second, 10*time.Second)
+
+	if err := rm.SetConfig("Game", "100%"); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	if golds := rm.GetGoldSegments(); !slices.Equal(golds, []time.Duration{0, 0}) {
+		t.Errorf("golds of a new category = %v, want none", golds)
+	}
+	recordRun(t, rm, 30*time.Second, 40*time.Second)
+	if golds := rm.GetGoldSegments(); !slices.Equal(golds, []time.Duration{30 * time.Second, 40 * time.Second}) {
+		t.Errorf("100%% golds = %v, want only its own run's segments", golds)
+	}
+	if sob := rm.GetSumOfBest(); sob != 70*time.Second {
+		t.Errorf("100%% sum of best = %v, want 1m10s", sob)
+	}
+	if average := rm.GetComparisonSegments(ComparisonAverage); !slices.Equal(average, []time.Duration{30 * time.Second, 40 * time.Second}) {
+		t.Errorf("100%% average = %v, want only its own run's segments", average)
+	}
+	stats, err := rm.GetSplitStatistics(0)
+	if err != nil {
+		t.Fatalf("GetSplitStatistics: %v", err)
+	}
+	if stats.SampleCount != 1 || stats.Min != 30*time.Second {
+		t.Errorf("100%% stats = %+v, want one sample of 30s", stats)
+	}
+
+	if err := rm.SetConfig("Game", "Any%"); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	if golds := rm.GetGoldSegments(); !slices.Equal(golds, []time.Duration{10 * time.Second, 10 * time.Second}) {
+		t.Errorf("Any%% golds after switching back = %v, want 10s, 10s", golds)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	opts := DefaultNewRunManagerOptions
+	opts.Debug = true
+	rm, err := NewRunManagerWithOptions(filepath.Join(t.TempDir(), "test.db"), opts)
+	if err != nil {
+		t.Fatalf("NewRunManagerWithOptions: %v", err)
+	}
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	if err := rm.Close(); err != nil {
+		t.Fatalf("first Close: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("first Close logged %q", logs.String())
+	}
+	if err := rm.Close(); err != nil {
+		t.Errorf("second Close = %v, want nil", err)
+	}
+	if !strings.Contains(logs.String(), "closed more than once") {
+		t.Errorf("second Close in debug mode logged %q, want a warning", logs.String())
+	}
+}
+
+func TestSetSplitNodesRekeysGolds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	rm := openTestRunManager(t, path, "A", "B", "C")
+	recordRun(t, rm, 10*time.Second, 20*time.Second, 30*time.Second)
+	// A stored gold for C that no run backs, e.g. from an imported file
+	if _, err := rm.db.Exec("UPDATE best_segments SET duration_ns = ? WHERE split_index = 2", time.Second.Nanoseconds()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rm.SetSplitNames([]string{"A", "B"}); err != nil {
+		t.Fatalf("SetSplitNames: %v", err)
+	}
+	var stale int
+	if err := rm.db.QueryRow("SELECT COUNT(*) FROM best_segments WHERE split_index >= 2").Scan(&stale); err != nil {
+		t.Fatal(err)
+	}
+	if stale != 0 {
+		t.Errorf("%d golds stored for removed splits", stale)
+	}
+
+	// Adding C back doesn't bring back the old stored gold
+	if err := rm.SetSplitNames([]string{"A", "B", "C"}); err != nil {
+		t.Fatalf("SetSplitNames: %v", err)
+	}
+	rm.Close()
+	reopened := openTestRunManager(t, path)
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second}
+	if golds := reopened.GetGoldSegments(); !slices.Equal(golds, want) {
+		t.Errorf("golds = %v, want %v", golds, want)
+	}
+}
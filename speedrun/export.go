@@ -0,0 +1,47 @@
+package speedrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// exportSnapshot is what ExportToJSON and ExportToLSS write, copied under a
+// single lock so both formats are built from the same data
+type exportSnapshot struct {
+	title     string
+	category  string
+	attempts  int
+	completed int
+	splits    []SplitNode
+	// golds holds the gold of each split, 0 where unknown
+	golds []time.Duration
+	pb    *Run
+}
+
+// exportSnapshot copies the data to export. The caller must hold rm.mu.
+func (rm *RunManager) exportSnapshot() exportSnapshot {
+	return exportSnapshot{
+		title:     rm.title,
+		category:  rm.category,
+		attempts:  rm.attempts,
+		completed: rm.completedRuns,
+		splits:    rm.splitNodes(),
+		golds:     append([]time.Duration(nil), rm.goldSegments...),
+		pb:        copyRun(rm.pb),
+	}
+}
+
+// ExportRunsSinceID writes every run with an ID above afterID to w as NDJSON,
+// one Run per line in ID order. Runs are streamed from the database one at a
+// time, so a sync can pass the last ID it has seen and fetch only new runs.
+func (rm *RunManager) ExportRunsSinceID(afterID int64, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return rm.forEachRun("runs.id > ?", []interface{}{afterID}, "runs.id", func(run *Run) error {
+		if err := enc.Encode(run); err != nil {
+			return fmt.Errorf("error writing run %d: %w", run.ID, err)
+		}
+		return nil
+	})
+}
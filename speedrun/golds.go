@@ -0,0 +1,117 @@
+package speedrun
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// loadBestSegments reads the stored gold for each of numSplits splits. The
+// second result is false if nothing has been stored yet.
+func loadBestSegments(db *sql.DB, numSplits int) ([]time.Duration, bool, error) {
+	rows, err := db.Query("SELECT split_index, duration_ns FROM best_segments")
+	if err != nil {
+		return nil, false, fmt.Errorf("error loading best segments: %w", err)
+	}
+	defer rows.Close()
+
+	golds := make([]time.Duration, numSplits)
+	found := false
+	for rows.Next() {
+		var idx int
+		var durationNs int64
+		if err := rows.Scan(&idx, &durationNs); err != nil {
+			return nil, false, fmt.Errorf("error scanning best segment: %w", err)
+		}
+		found = true
+		if idx >= 0 && idx < numSplits {
+			golds[idx] = time.Duration(durationNs)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("error loading best segments: %w", err)
+	}
+	return golds, found, nil
+}
+
+// storeBestSegments replaces the stored golds with rm.goldSegments
+func (rm *RunManager) storeBestSegments() error {
+	tx, err := rm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM best_segments"); err != nil {
+		return fmt.Errorf("error clearing best segments: %w", err)
+	}
+	for i, gold := range rm.goldSegments {
+		if gold == 0 {
+			continue
+		}
+		_, err := tx.Exec("INSERT INTO best_segments (split_index, split_name, duration_ns) VALUES (?, ?, ?)",
+			i, rm.splitNames[i], gold.Nanoseconds())
+		if err != nil {
+			return fmt.Errorf("error storing best segment: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// mergeGolds stores in tx every time in candidates that beats the current
+// gold, skipping those for which usable returns false, and returns the golds
+// with them included. rm.goldSegments is left alone so the caller can swap
+// the result in once tx commits.
+func (rm *RunManager) mergeGolds(tx *sql.Tx, candidates []time.Duration, usable func(i int) bool) ([]time.Duration, error) {
+	golds := append([]time.Duration(nil), rm.goldSegments...)
+	for i, d := range candidates {
+		if i >= len(golds) || !usable(i) {
+			continue
+		}
+		if golds[i] != 0 && d >= golds[i] {
+			continue
+		}
+		_, err := tx.Exec("INSERT OR REPLACE INTO best_segments (split_index, split_name, duration_ns) VALUES (?, ?, ?)",
+			i, rm.splitNames[i], d.Nanoseconds())
+		if err != nil {
+			return nil, fmt.Errorf("error storing best segment: %w", err)
+		}
+		golds[i] = d
+	}
+	return golds, nil
+}
+
+// importGolds stores any of golds that beats the current gold. Unlike the
+// segments of a run, a zero in golds just means the gold is unknown.
+func (rm *RunManager) importGolds(golds []time.Duration) error {
+	tx, err := rm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	merged, err := rm.mergeGolds(tx, golds, func(i int) bool { return golds[i] > 0 })
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	rm.goldSegments = merged
+	rm.applyGoldsToPB()
+	return nil
+}
+
+// applyGoldsToPB copies rm.goldSegments into the PB's BestSegment fields
+func (rm *RunManager) applyGoldsToPB() {
+	if rm.pb == nil {
+		return
+	}
+	for i := range rm.pb.Splits {
+		if i < len(rm.goldSegments) {
+			rm.pb.Splits[i].BestSegment = rm.goldSegments[i]
+		} else {
+			rm.pb.Splits[i].BestSegment = 0
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package speedrun
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -15,8 +20,12 @@ type SpeedrunJSON struct {
 	Attempts     int           `json:"attempts"`
 	Completed    int           `json:"completed"`
 	SplitNames   []string      `json:"split_names"`
+	SplitDepths  []int         `json:"split_depths,omitempty"`
 	Golds        []interface{} `json:"golds"`
 	PersonalBest *PBData       `json:"personal_best"`
+	// History holds other completed runs. The fastest of these and the
+	// personal best becomes the PB.
+	History []PBData `json:"history,omitempty"`
 }
 
 // PBData represents personal best data in the JSON
@@ -32,22 +41,79 @@ type PBSplit struct {
 
 // ImportFromJSON loads speedrun configuration from a JSON file
 func (rm *RunManager) ImportFromJSON(filepath string) error {
-	// Read JSON file
-	jsonData, err := ioutil.ReadFile(filepath)
+	speedrun, err := readSpeedrunJSON(filepath)
 	if err != nil {
-		return fmt.Errorf("failed to read JSON file: %v", err)
+		return err
+	}
+	return rm.importSpeedrun(speedrun)
+}
+
+// ImportFiles imports each file in order, as JSON or, for .lss files, as
+// LiveSplit splits. Later files replace the splits and PB of earlier ones,
+// but golds are only ever improved. All files must be for the same game:
+// they are read and checked before anything is imported.
+func (rm *RunManager) ImportFiles(paths ...string) error {
+	var speedruns []*SpeedrunJSON
+	for i, path := range paths {
+		var speedrun *SpeedrunJSON
+		var err error
+		if strings.EqualFold(filepath.Ext(path), ".lss") {
+			speedrun, err = readLSS(path, RealTime)
+		} else {
+			speedrun, err = readSpeedrunJSON(path)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if i > 0 && speedrun.Title != speedruns[0].Title {
+			return fmt.Errorf("cannot import %s: its title %q conflicts with %q from %s",
+				path, speedrun.Title, speedruns[0].Title, paths[0])
+		}
+		speedruns = append(speedruns, speedrun)
+	}
+
+	for i, speedrun := range speedruns {
+		if err := rm.importSpeedrun(speedrun); err != nil {
+			return fmt.Errorf("%s: %w", paths[i], err)
+		}
+	}
+	return nil
+}
+
+// readSpeedrunJSON reads and parses a JSON import file
+func readSpeedrunJSON(filepath string) (*SpeedrunJSON, error) {
+	jsonData, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON file: %w", err)
 	}
 
-	// Parse JSON
 	var speedrun SpeedrunJSON
 	if err := json.Unmarshal(jsonData, &speedrun); err != nil {
-		return fmt.Errorf("failed to parse JSON: %v", err)
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return &speedrun, nil
+}
+
+// importSpeedrun replaces the config, split names and PB with the ones in speedrun
+func (rm *RunManager) importSpeedrun(speedrun *SpeedrunJSON) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	golds := make([]time.Duration, len(speedrun.Golds))
+	for i, gold := range speedrun.Golds {
+		if s, ok := gold.(string); ok {
+			d, err := parseSplitTime(s)
+			if err != nil {
+				return fmt.Errorf("gold %d: %w", i+1, err)
+			}
+			golds[i] = d
+		}
 	}
 
 	// Start a transaction
 	tx, err := rm.db.Begin()
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -55,114 +121,302 @@ func (rm *RunManager) ImportFromJSON(filepath string) error {
 	_, err = tx.Exec("UPDATE config SET title = ?, category = ?, attempts = ?, completed = ? WHERE id = 1",
 		speedrun.Title, speedrun.Category, speedrun.Attempts, speedrun.Completed)
 	if err != nil {
-		return fmt.Errorf("error updating config: %v", err)
+		return fmt.Errorf("error updating config: %w", err)
 	}
 
 	// Delete existing split names
 	_, err = tx.Exec("DELETE FROM split_names")
 	if err != nil {
-		return fmt.Errorf("error deleting existing split names: %v", err)
+		return fmt.Errorf("error deleting existing split names: %w", err)
 	}
 
 	// Insert new split names
+	splitDepths := make([]int, len(speedrun.SplitNames))
+	copy(splitDepths, speedrun.SplitDepths)
 	for i, name := range speedrun.SplitNames {
-		_, err = tx.Exec("INSERT INTO split_names (name, display_order) VALUES (?, ?)", name, i)
+		_, err = tx.Exec("INSERT INTO split_names (name, display_order, subsplit_depth) VALUES (?, ?, ?)",
+			name, i, splitDepths[i])
 		if err != nil {
-			return fmt.Errorf("error inserting split name: %v", err)
+			return fmt.Errorf("error inserting split name: %w", err)
 		}
 	}
 
 	// Delete any existing PB
-	_, err = tx.Exec("UPDATE runs SET is_pb = 0 WHERE is_pb = 1")
+	_, err = tx.Exec("UPDATE runs SET is_pb = 0 WHERE is_pb = 1 AND category = ?", speedrun.Category)
 	if err != nil {
-		return fmt.Errorf("error resetting previous PB: %v", err)
+		return fmt.Errorf("error resetting previous PB: %w", err)
 	}
 
-	// Insert personal best if available
-	if speedrun.PersonalBest != nil && len(speedrun.PersonalBest.Splits) > 0 {
-		// Use a placeholder start time (24h ago)
-		startTime := time.Now().Add(-24 * time.Hour)
+	// Insert the history and the personal best, oldest first, and mark the
+	// fastest complete one with no skipped split as PB
+	var candidates []PBData
+	candidates = append(candidates, speedrun.History...)
+	if speedrun.PersonalBest != nil {
+		candidates = append(candidates, *speedrun.PersonalBest)
+	}
+	var pbID int64
+	var pbTotal time.Duration
+	for i, run := range candidates {
+		if len(run.Splits) == 0 {
+			continue
+		}
+		if len(run.Splits) > len(speedrun.SplitNames) {
+			return fmt.Errorf("imported run has %d splits, but there are only %d split names",
+				len(run.Splits), len(speedrun.SplitNames))
+		}
 
-		// Calculate split durations and end time
-		splits := make([]time.Duration, len(speedrun.PersonalBest.Splits))
+		// Use placeholder start times, a day apart and ending 24h ago
+		startTime := time.Now().Add(-time.Duration(len(candidates)-i) * 24 * time.Hour)
+		runID, splits, err := insertImportedRun(tx, speedrun, run, startTime)
+		if err != nil {
+			return err
+		}
+		if len(splits) < len(speedrun.SplitNames) || !pbEligible(splits) {
+			continue
+		}
 		var totalTime time.Duration
+		for _, split := range splits {
+			totalTime += split
+		}
+		if pbID == 0 || totalTime < pbTotal {
+			pbID, pbTotal = runID, totalTime
+		}
+	}
+	if pbID != 0 {
+		if _, err := tx.Exec("UPDATE runs SET is_pb = 1, pb_time_ns = ? WHERE id = ?", pbTotal.Nanoseconds(), pbID); err != nil {
+			return fmt.Errorf("error setting imported PB: %w", err)
+		}
+	}
 
-		for i, split := range speedrun.PersonalBest.Splits {
-			// Parse the time string (expected format: "m:ss.fff" or "ss.fff")
-			parts := strings.Split(split.Time, ":")
-			var minutes, seconds float64
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
 
-			if len(parts) == 2 {
-				fmt.Sscanf(parts[0], "%f", &minutes)
-				fmt.Sscanf(parts[1], "%f", &seconds)
-			} else {
-				fmt.Sscanf(parts[0], "%f", &seconds)
-			}
+	// Update the RunManager fields
+	rm.title = speedrun.Title
+	rm.category = speedrun.Category
+	rm.attempts = speedrun.Attempts
+	rm.completedRuns = speedrun.Completed
+	rm.setSplitNames(speedrun.SplitNames, splitDepths)
 
-			// For absolute splits, calculate the individual split duration
-			var splitDuration time.Duration
-			if i == 0 {
-				splitDuration = time.Duration(minutes*60*float64(time.Second) + seconds*float64(time.Second))
-			} else {
-				currentTotal := time.Duration(minutes*60*float64(time.Second) + seconds*float64(time.Second))
-				prevTotal := totalTime
-				splitDuration = currentTotal - prevTotal
-			}
+	// Reload PB
+	rm.pb, err = loadPersonalBest(rm.db, rm.category)
+	if err != nil {
+		return fmt.Errorf("failed to reload PB after import: %w", err)
+	}
+	if err := rm.computeBestSegments(); err != nil {
+		return fmt.Errorf("failed to compute best segments after import: %w", err)
+	}
+
+	// Golds from the file can beat anything in the imported runs
+	if err := rm.importGolds(golds); err != nil {
+		return fmt.Errorf("failed to store imported golds: %w", err)
+	}
+
+	return nil
+}
+
+// insertImportedRun inserts run as a non-PB run starting at startTime and
+// returns its ID and segment times. It is completed if it has every split.
+func insertImportedRun(tx *sql.Tx, speedrun *SpeedrunJSON, run PBData, startTime time.Time) (int64, []time.Duration, error) {
+	// Calculate split durations and end time
+	splits, err := parseSegmentDurations(run.Splits)
+	if err != nil {
+		return 0, nil, err
+	}
+	var totalTime time.Duration
+	for _, splitDuration := range splits {
+		totalTime += splitDuration
+	}
+
+	endTime := startTime.Add(totalTime)
 
-			splits[i] = splitDuration
-			totalTime += splitDuration
+	result, err := tx.Exec(`
+		INSERT INTO runs 
+		(title, category, start_time, end_time, completed, is_pb, attempt_num)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`,
+		speedrun.Title, speedrun.Category,
+		startTime.Format(time.RFC3339), endTime.Format(time.RFC3339),
+		sqlite3Bool(len(splits) == len(speedrun.SplitNames)), sqlite3Bool(false), run.Attempt,
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error inserting imported run: %w", err)
+	}
+
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return 0, nil, fmt.Errorf("error getting last insert ID: %w", err)
+	}
+
+	for i, splitDuration := range splits {
+		_, err = tx.Exec(`
+			INSERT INTO splits (run_id, split_index, split_name, duration_ns)
+			VALUES (?, ?, ?, ?)
+		`, runID, i, speedrun.SplitNames[i], splitDuration.Nanoseconds())
+		if err != nil {
+			return 0, nil, fmt.Errorf("error inserting imported split: %w", err)
 		}
+	}
+	return runID, splits, nil
+}
 
-		endTime := startTime.Add(totalTime)
+// parseSegmentDurations turns the absolute split times of a PB into the
+// duration of each individual segment
+func parseSegmentDurations(pbSplits []PBSplit) ([]time.Duration, error) {
+	splits := make([]time.Duration, len(pbSplits))
+	var totalTime time.Duration
 
-		// Insert the PB run
-		result, err := tx.Exec(`
-			INSERT INTO runs 
-			(title, category, start_time, end_time, completed, is_pb, attempt_num)
-			VALUES (?, ?, ?, ?, ?, ?, ?)
-		`,
-			speedrun.Title, speedrun.Category,
-			startTime.Format(time.RFC3339), endTime.Format(time.RFC3339),
-			sqlite3Bool(true), sqlite3Bool(true), speedrun.PersonalBest.Attempt,
-		)
+	for i, split := range pbSplits {
+		splitTime, err := parseSplitTime(split.Time)
 		if err != nil {
-			return fmt.Errorf("error inserting PB run: %v", err)
+			return nil, fmt.Errorf("split %d: %w", i+1, err)
 		}
 
-		runID, err := result.LastInsertId()
+		// For absolute splits, calculate the individual split duration
+		splitDuration := splitTime - totalTime
+
+		splits[i] = splitDuration
+		totalTime += splitDuration
+	}
+
+	return splits, nil
+}
+
+// parseSplitTime parses a time string in the format "h:mm:ss.fff", "m:ss.fff"
+// or "ss.fff". The fraction is optional and can have any number of digits.
+func parseSplitTime(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q: too many fields", s)
+	}
+
+	// Every field but the seconds is a whole number
+	var d time.Duration
+	units := []time.Duration{time.Hour, time.Minute}[3-len(parts):]
+	for i, part := range parts[:len(parts)-1] {
+		if !isDigits(part) {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		n, err := strconv.Atoi(part)
 		if err != nil {
-			return fmt.Errorf("error getting last insert ID: %v", err)
+			return 0, fmt.Errorf("invalid time %q: %w", s, err)
 		}
+		d += time.Duration(n) * units[i]
+	}
 
-		// Insert PB splits
-		for i, splitDuration := range splits {
-			_, err = tx.Exec(`
-				INSERT INTO splits (run_id, split_index, split_name, duration_ns)
-				VALUES (?, ?, ?, ?)
-			`, runID, i, speedrun.SplitNames[i], splitDuration.Nanoseconds())
-			if err != nil {
-				return fmt.Errorf("error inserting PB split: %v", err)
-			}
+	whole, frac, _ := strings.Cut(parts[len(parts)-1], ".")
+	if !isDigits(whole) || (frac != "" && !isDigits(frac)) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	seconds, err := strconv.ParseFloat(whole+"."+frac, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	return d + time.Duration(math.Round(seconds*float64(time.Second))), nil
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
 		}
 	}
+	return true
+}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+// ExportToJSON writes the config, split names, golds and PB to w in the format
+// read by ImportFromJSON. Golds are segment times, null where unknown, and
+// personal_best is null when there is no PB.
+func (rm *RunManager) ExportToJSON(w io.Writer) error {
+	rm.mu.RLock()
+	snapshot := rm.exportSnapshot()
+	rm.mu.RUnlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snapshot.speedrunJSON()); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
 	}
+	return nil
+}
 
-	// Update the RunManager fields
-	rm.title = speedrun.Title
-	rm.category = speedrun.Category
-	rm.attempts = speedrun.Attempts
-	rm.completedRuns = speedrun.Completed
-	rm.splitNames = speedrun.SplitNames
+// speedrunJSON converts the snapshot into the JSON import format
+func (s exportSnapshot) speedrunJSON() SpeedrunJSON {
+	speedrun := SpeedrunJSON{
+		Title:      s.title,
+		Category:   s.category,
+		Attempts:   s.attempts,
+		Completed:  s.completed,
+		SplitNames: make([]string, len(s.splits)),
+		Golds:      make([]interface{}, len(s.splits)),
+	}
+	depths := make([]int, len(s.splits))
+	hasDepths := false
+	for i, node := range s.splits {
+		speedrun.SplitNames[i] = node.Name
+		depths[i] = node.Depth
+		hasDepths = hasDepths || node.Depth != 0
+	}
+	// Files without subsplits leave split_depths out
+	if hasDepths {
+		speedrun.SplitDepths = depths
+	}
+	for i, gold := range s.golds {
+		if i < len(speedrun.Golds) && gold > 0 {
+			speedrun.Golds[i] = formatSplitTime(gold)
+		}
+	}
+	if s.pb != nil {
+		speedrun.PersonalBest = &PBData{Attempt: s.pb.AttemptNum}
+		for i := range s.pb.Splits {
+			speedrun.PersonalBest.Splits = append(speedrun.PersonalBest.Splits, PBSplit{
+				Time: formatSplitTime(s.pb.TimeSince(i)),
+			})
+		}
+	}
+	return speedrun
+}
 
-	// Reload PB
-	rm.pb, err = loadPersonalBest(rm.db)
+// formatSplitTime formats d as "m:ss.fff", as read by parseSegmentDurations
+func formatSplitTime(d time.Duration) string {
+	return fmt.Sprintf("%d:%06.3f", int(d.Minutes()), (d % time.Minute).Seconds())
+}
+
+// LoadComparisonFromJSON loads the PB splits from a JSON file (same format as
+// ImportFromJSON) as an extra in-memory comparison, selectable with
+// ComparisonExternal. The database is never touched.
+func (rm *RunManager) LoadComparisonFromJSON(filepath string, label string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	jsonData, err := os.ReadFile(filepath)
 	if err != nil {
-		return fmt.Errorf("failed to reload PB after import: %v", err)
+		return fmt.Errorf("failed to read JSON file: %w", err)
+	}
+
+	var speedrun SpeedrunJSON
+	if err := json.Unmarshal(jsonData, &speedrun); err != nil {
+		return fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
+	if speedrun.PersonalBest == nil || len(speedrun.PersonalBest.Splits) == 0 {
+		return fmt.Errorf("comparison file has no personal best splits")
+	}
+	if len(speedrun.PersonalBest.Splits) != rm.numSplits {
+		return fmt.Errorf("comparison file has %d splits, expected %d",
+			len(speedrun.PersonalBest.Splits), rm.numSplits)
+	}
+
+	splits, err := parseSegmentDurations(speedrun.PersonalBest.Splits)
+	if err != nil {
+		return fmt.Errorf("invalid comparison file: %w", err)
+	}
+	rm.externalComparison = splits
+	rm.externalComparisonLabel = label
 	return nil
 }
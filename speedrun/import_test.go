@@ -0,0 +1,180 @@
+package speedrun
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadComparisonFromJSON(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B", "C")
+	recordRun(t, rm, 10*time.Second, 20*time.Second, 30*time.Second)
+
+	path := filepath.Join(t.TempDir(), "rival.json")
+	rival := `{"personal_best": {"splits": [{"time": "9"}, {"time": "35"}, {"time": "1:00.5"}]}}`
+	if err := os.WriteFile(path, []byte(rival), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.LoadComparisonFromJSON(path, "Rival"); err != nil {
+		t.Fatalf("LoadComparisonFromJSON: %v", err)
+	}
+
+	want := []time.Duration{9 * time.Second, 26 * time.Second, 25500 * time.Millisecond}
+	if got := rm.GetComparisonSegments(ComparisonExternal); !slices.Equal(got, want) {
+		t.Errorf("external segments = %v, want %v", got, want)
+	}
+	if label := rm.ComparisonLabel(ComparisonExternal); label != "Rival" {
+		t.Errorf("label = %q, want Rival", label)
+	}
+	// Diffs are against the file: the PB run lost most time on C against it
+	index, loss := rm.WorstSplit(*rm.GetPersonalBest(), ComparisonExternal)
+	if index != 2 || loss != 4500*time.Millisecond {
+		t.Errorf("WorstSplit vs the file = %d, %v, want 2, 4.5s", index, loss)
+	}
+
+	// The database PB is untouched
+	if pb := rm.GetPersonalBest(); pb.PBTime != time.Minute {
+		t.Errorf("PB time = %v, want 1m0s", pb.PBTime)
+	}
+	if got := rm.GetComparisonSegments(ComparisonPB); !slices.Equal(got, []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second}) {
+		t.Errorf("PB segments = %v, changed by the comparison file", got)
+	}
+	var runs int
+	if err := rm.db.QueryRow("SELECT COUNT(*) FROM runs").Scan(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("got %d runs in the database, want 1", runs)
+	}
+
+	// A file for another split layout is refused
+	short := `{"personal_best": {"splits": [{"time": "9"}]}}`
+	if err := os.WriteFile(path, []byte(short), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.LoadComparisonFromJSON(path, "Short"); err == nil {
+		t.Error("loading a comparison with the wrong number of splits succeeded")
+	}
+}
+
+func TestImportFromJSONMultiHour(t *testing.T) {
+	rm := newTestRunManager(t)
+	path := filepath.Join(t.TempDir(), "marathon.json")
+	splits := `{
+		"title": "Marathon", "category": "100%", "attempts": 3, "completed": 1,
+		"split_names": ["Start", "Middle", "End"],
+		"golds": ["45:00", "1:10:00.5", null],
+		"personal_best": {"attempt": 2, "splits": [
+			{"time": "59:59.9"}, {"time": "1:23:45.678"}, {"time": "2:00:00"}
+		]}
+	}`
+	if err := os.WriteFile(path, []byte(splits), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.ImportFromJSON(path); err != nil {
+		t.Fatalf("ImportFromJSON: %v", err)
+	}
+
+	pb := rm.GetPersonalBest()
+	if pb == nil {
+		t.Fatal("no PB after import")
+	}
+	want := []time.Duration{
+		59*time.Minute + 59900*time.Millisecond,
+		23*time.Minute + 45778*time.Millisecond,
+		36*time.Minute + 14322*time.Millisecond,
+	}
+	for i, w := range want {
+		if got := pb.Splits[i].Duration; got != w {
+			t.Errorf("PB segment %d = %v, want %v", i, got, w)
+		}
+	}
+	if pb.PBTime != 2*time.Hour {
+		t.Errorf("PB time = %v, want 2h0m0s", pb.PBTime)
+	}
+	// The file's gold for Middle is slower than the PB's own segment
+	wantGolds := []time.Duration{45 * time.Minute, want[1], want[2]}
+	if golds := rm.GetGoldSegments(); !slices.Equal(golds, wantGolds) {
+		t.Errorf("golds = %v, want %v", golds, wantGolds)
+	}
+}
+
+func TestImportFromJSONPBEligibility(t *testing.T) {
+	rm := newTestRunManager(t)
+	path := filepath.Join(t.TempDir(), "history.json")
+	// The first run skips Middle and the second stops after it, both faster
+	// than the last, full run
+	splits := `{
+		"title": "Game", "category": "Any%", "attempts": 3, "completed": 2,
+		"split_names": ["Start", "Middle", "End"],
+		"history": [
+			{"attempt": 1, "splits": [{"time": "10"}, {"time": "10"}, {"time": "30"}]},
+			{"attempt": 2, "splits": [{"time": "9"}, {"time": "20"}]}
+		],
+		"personal_best": {"attempt": 3, "splits": [{"time": "12"}, {"time": "25"}, {"time": "40"}]}
+	}`
+	if err := os.WriteFile(path, []byte(splits), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.ImportFromJSON(path); err != nil {
+		t.Fatalf("ImportFromJSON: %v", err)
+	}
+
+	pb := rm.GetPersonalBest()
+	if pb == nil || pb.AttemptNum != 3 || pb.PBTime != 40*time.Second {
+		t.Fatalf("PB = %+v, want attempt 3 in 40s", pb)
+	}
+	runs, err := rm.ListRuns(3, 0)
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	for _, run := range runs {
+		if want := run.AttemptNum != 2; run.Completed != want {
+			t.Errorf("attempt %d completed = %v, want %v", run.AttemptNum, run.Completed, want)
+		}
+	}
+}
+
+func TestParseSplitTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "1:02:03.45", want: time.Hour + 2*time.Minute + 3450*time.Millisecond},
+		{in: "10:00:00", want: 10 * time.Hour},
+		{in: "1:23:45.678", want: time.Hour + 23*time.Minute + 45678*time.Millisecond},
+		{in: "2:05.5", want: 2*time.Minute + 5500*time.Millisecond},
+		{in: "0:59.999", want: 59999 * time.Millisecond},
+		{in: "75:00", want: 75 * time.Minute},
+		{in: "42.123", want: 42123 * time.Millisecond},
+		{in: "7", want: 7 * time.Second},
+		{in: "3.1415926", want: 3141592600 * time.Nanosecond},
+		{in: "", wantErr: true},
+		{in: "-5.0", wantErr: true},
+		{in: "-1:00:00", wantErr: true},
+		{in: "1:-2:03", wantErr: true},
+		{in: "1:2:3:4", wantErr: true},
+		{in: "1::03", wantErr: true},
+		{in: "1:02.", want: time.Minute + 2*time.Second},
+		{in: ".5", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: " 1:00", wantErr: true},
+		{in: "1e3", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSplitTime(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSplitTime(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseSplitTime(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package speedrun
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Actions that can be bound to a global hotkey
+const (
+	ActionSplit = "split"
+	ActionReset = "reset"
+	ActionUndo  = "undo"
+	ActionSkip  = "skip"
+	ActionPause = "pause"
+)
+
+// Keybind is the hotkey for one action. Key and Modifiers are the platform's
+// raw values, as passed to the hotkey library: on Windows Key is a virtual-key
+// code and Modifiers is a mix of Alt (1), Ctrl (2), Shift (4) and Win (8).
+type Keybind struct {
+	Key       uint16
+	Modifiers uint32
+}
+
+// Actions lists every bindable action, in the order they are registered
+var Actions = []string{ActionSplit, ActionReset, ActionUndo, ActionSkip, ActionPause}
+
+// DefaultKeybinds are the keys used before keybinds were configurable, plus P
+// for pause. The values are Windows virtual-key codes.
+var DefaultKeybinds = map[string]Keybind{
+	ActionSplit: {Key: 0x53}, // S
+	ActionReset: {Key: 0x55}, // U
+	ActionUndo:  {Key: 0x5B}, // Left Windows key
+	ActionSkip:  {Key: 0x54}, // T
+	ActionPause: {Key: 0x50}, // P
+}
+
+// initKeybinds creates the keybinds table, filling in the default for any
+// action that has no binding yet
+func initKeybinds(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS keybinds (
+			action TEXT PRIMARY KEY,
+			key INTEGER NOT NULL,
+			modifiers INTEGER NOT NULL DEFAULT 0
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating keybinds table: %w", err)
+	}
+	for _, action := range Actions {
+		kb := DefaultKeybinds[action]
+		_, err := db.Exec("INSERT OR IGNORE INTO keybinds (action, key, modifiers) VALUES (?, ?, ?)",
+			action, kb.Key, kb.Modifiers)
+		if err != nil {
+			return fmt.Errorf("error storing default %s keybind: %w", action, err)
+		}
+	}
+	return nil
+}
+
+// GetKeybinds returns the hotkey for every action. It returns an error if the
+// stored bindings are invalid, e.g. after the table was edited by hand.
+func (rm *RunManager) GetKeybinds() (map[string]Keybind, error) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.keybinds()
+}
+
+func (rm *RunManager) keybinds() (map[string]Keybind, error) {
+	rows, err := rm.db.Query("SELECT action, key, modifiers FROM keybinds")
+	if err != nil {
+		return nil, fmt.Errorf("error loading keybinds: %w", err)
+	}
+	defer rows.Close()
+
+	keybinds := make(map[string]Keybind)
+	for rows.Next() {
+		var action string
+		var key int64
+		var kb Keybind
+		if err := rows.Scan(&action, &key, &kb.Modifiers); err != nil {
+			return nil, fmt.Errorf("error scanning keybind: %w", err)
+		}
+		if key <= 0 || key > 0xFFFF {
+			return nil, fmt.Errorf("invalid key %d for %s", key, action)
+		}
+		kb.Key = uint16(key)
+		keybinds[action] = kb
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading keybinds: %w", err)
+	}
+
+	if err := checkKeybinds(keybinds); err != nil {
+		return nil, err
+	}
+	return keybinds, nil
+}
+
+// UpdateKeybinds is the old name of SetKeybinds.
+//
+// Deprecated: use SetKeybinds.
+func (rm *RunManager) UpdateKeybinds(keybinds map[string]Keybind) error {
+	return rm.SetKeybinds(keybinds)
+}
+
+// SetKeybinds replaces the hotkeys for the given actions. Actions left out
+// keep their current binding. The result must bind every action to a
+// different key, or nothing is changed.
+func (rm *RunManager) SetKeybinds(keybinds map[string]Keybind) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	current, err := rm.keybinds()
+	if err != nil {
+		return err
+	}
+	for action, kb := range keybinds {
+		current[action] = kb
+	}
+	if err := checkKeybinds(current); err != nil {
+		return err
+	}
+
+	tx, err := rm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for action, kb := range keybinds {
+		_, err := tx.Exec("INSERT OR REPLACE INTO keybinds (action, key, modifiers) VALUES (?, ?, ?)",
+			action, kb.Key, kb.Modifiers)
+		if err != nil {
+			return fmt.Errorf("error storing %s keybind: %w", action, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// checkKeybinds returns an error unless keybinds binds exactly the known
+// actions, each to a different key
+func checkKeybinds(keybinds map[string]Keybind) error {
+	for action := range keybinds {
+		if _, ok := DefaultKeybinds[action]; !ok {
+			return fmt.Errorf("unknown keybind action %q", action)
+		}
+	}
+	bound := make(map[Keybind]string)
+	for _, action := range Actions {
+		kb, ok := keybinds[action]
+		if !ok {
+			return fmt.Errorf("no keybind for %s", action)
+		}
+		if kb.Key == 0 {
+			return fmt.Errorf("invalid key 0 for %s", action)
+		}
+		if other, ok := bound[kb]; ok {
+			return fmt.Errorf("%s and %s are bound to the same key", other, action)
+		}
+		bound[kb] = action
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package speedrun
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// TimingMethod picks which of LiveSplit's two clocks to import
+type TimingMethod int
+
+const (
+	// RealTime is the time as shown on a wall clock
+	RealTime TimingMethod = iota
+	// GameTime is the time as measured by the game, e.g. without loads
+	GameTime
+)
+
+// lssRun is the part of a LiveSplit .lss file that gets imported
+type lssRun struct {
+	GameName     string       `xml:"GameName"`
+	CategoryName string       `xml:"CategoryName"`
+	AttemptCount int          `xml:"AttemptCount"`
+	Attempts     []lssAttempt `xml:"AttemptHistory>Attempt"`
+	Segments     []lssSegment `xml:"Segments>Segment"`
+}
+
+// lssTime is a time measured on both clocks. Either can be empty.
+type lssTime struct {
+	RealTime string `xml:"RealTime"`
+	GameTime string `xml:"GameTime"`
+}
+
+// get returns the time on the clock picked by method
+func (t lssTime) get(method TimingMethod) string {
+	if method == GameTime {
+		return t.GameTime
+	}
+	return t.RealTime
+}
+
+// lssAttempt is one attempt of the history. Only finished attempts have a
+// time.
+type lssAttempt struct {
+	ID int `xml:"id,attr"`
+	lssTime
+}
+
+// lssSegment is one split of a LiveSplit run
+type lssSegment struct {
+	Name       string         `xml:"Name"`
+	SplitTimes []lssSplitTime `xml:"SplitTimes>SplitTime"`
+	Best       lssTime        `xml:"BestSegmentTime"`
+	History    []lssAttempt   `xml:"SegmentHistory>Time"`
+}
+
+// lssSplitTime is a split time of one of the comparisons, e.g. the PB
+type lssSplitTime struct {
+	Name string `xml:"name,attr"`
+	lssTime
+}
+
+// pbTime returns the segment's cumulative PB time, or "" if it has none
+func (s *lssSegment) pbTime(method TimingMethod) string {
+	for _, t := range s.SplitTimes {
+		if t.Name == "Personal Best" {
+			return t.get(method)
+		}
+	}
+	return ""
+}
+
+// ImportFromLSS loads the config, PB, golds and finished attempts from a
+// LiveSplit .lss file, the same way ImportFromJSON does, using real time.
+func (rm *RunManager) ImportFromLSS(filepath string) error {
+	return rm.ImportFromLSSWithTiming(filepath, RealTime)
+}
+
+// ImportFromLSSWithTiming is ImportFromLSS with a choice of clock
+func (rm *RunManager) ImportFromLSSWithTiming(filepath string, method TimingMethod) error {
+	speedrun, err := readLSS(filepath, method)
+	if err != nil {
+		return err
+	}
+	return rm.importSpeedrun(speedrun)
+}
+
+// readLSS reads a LiveSplit .lss file and translates it into the JSON import
+// format
+func readLSS(filepath string, method TimingMethod) (*SpeedrunJSON, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LSS file: %w", err)
+	}
+
+	var run lssRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse LSS: %w", err)
+	}
+	if len(run.Segments) == 0 {
+		return nil, fmt.Errorf("LSS file has no segments")
+	}
+
+	speedrun, err := lssToSpeedrun(&run, method)
+	if err != nil {
+		return nil, err
+	}
+	return &speedrun, nil
+}
+
+// lssToSpeedrun translates a LiveSplit run into the JSON import format
+func lssToSpeedrun(run *lssRun, method TimingMethod) (SpeedrunJSON, error) {
+	speedrun := SpeedrunJSON{
+		Title:       run.GameName,
+		Category:    run.CategoryName,
+		Attempts:    run.AttemptCount,
+		SplitNames:  make([]string, len(run.Segments)),
+		SplitDepths: make([]int, len(run.Segments)),
+		Golds:       make([]interface{}, len(run.Segments)),
+	}
+
+	pb := &PBData{Attempt: run.AttemptCount}
+	prev := "0"
+	for i, segment := range run.Segments {
+		speedrun.SplitNames[i], speedrun.SplitDepths[i] = lssSegmentName(segment.Name)
+		if best := segment.Best.get(method); best != "" {
+			speedrun.Golds[i] = best
+		}
+
+		// A PB split with no time was skipped, so it ends where the previous
+		// one did, giving it zero time
+		splitTime := prev
+		if t := segment.pbTime(method); t != "" {
+			splitTime = t
+		}
+		pb.Splits = append(pb.Splits, PBSplit{Time: splitTime})
+		prev = splitTime
+	}
+
+	// A PB without a final time never finished, so there is no PB to import
+	var pbTotal time.Duration
+	if pbFinal := run.Segments[len(run.Segments)-1].pbTime(method); pbFinal != "" {
+		speedrun.PersonalBest = pb
+		var err error
+		if pbTotal, err = parseSplitTime(pbFinal); err != nil {
+			return SpeedrunJSON{}, fmt.Errorf("invalid PB time: %w", err)
+		}
+	}
+
+	// Finished attempts become the history, except the PB's own attempt,
+	// which is already imported as the PB
+	for _, attempt := range run.Attempts {
+		if attempt.get(method) == "" {
+			continue
+		}
+		speedrun.Completed++
+		history, total, err := lssAttemptSplits(run.Segments, attempt.ID, method)
+		if err != nil {
+			return SpeedrunJSON{}, fmt.Errorf("attempt %d: %w", attempt.ID, err)
+		}
+		if total == 0 {
+			continue
+		}
+		if speedrun.PersonalBest != nil && (total-pbTotal).Abs() < time.Millisecond {
+			speedrun.PersonalBest.Attempt = attempt.ID
+			continue
+		}
+		speedrun.History = append(speedrun.History, history)
+	}
+	return speedrun, nil
+}
+
+// lssAttemptSplits rebuilds the cumulative split times of attempt id from the
+// segment history, and returns them with the total time. The total is 0 if the
+// last segment has no time, e.g. because the history was trimmed.
+func lssAttemptSplits(segments []lssSegment, id int, method TimingMethod) (PBData, time.Duration, error) {
+	run := PBData{Attempt: id}
+	var cumulative time.Duration
+	found := false
+	for i, segment := range segments {
+		// A segment with no time in a finished attempt was skipped
+		found = false
+		for _, t := range segment.History {
+			if t.ID == id && t.get(method) != "" {
+				d, err := parseSplitTime(t.get(method))
+				if err != nil {
+					return PBData{}, 0, fmt.Errorf("segment %d: %w", i+1, err)
+				}
+				cumulative += d
+				found = true
+				break
+			}
+		}
+		run.Splits = append(run.Splits, PBSplit{Time: formatSplitTime(cumulative)})
+	}
+	if !found {
+		return run, 0, nil
+	}
+	return run, cumulative, nil
+}
+
+// lssSegmentName returns the name and depth of a LiveSplit segment. LiveSplit
+// marks subsplits by starting their name with "-", and names the last one of
+// a group "{Group} Name".
+func lssSegmentName(name string) (string, int) {
+	if strings.HasPrefix(name, "-") {
+		return name[1:], 1
+	}
+	if strings.HasPrefix(name, "{") {
+		if end := strings.Index(name, "}"); end >= 0 {
+			return strings.TrimSpace(name[end+1:]), 1
+		}
+	}
+	return name, 0
+}
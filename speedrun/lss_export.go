@@ -0,0 +1,190 @@
+package speedrun
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// lssExportVersion is the LiveSplit file format version written by
+// ExportToLSS
+const lssExportVersion = "1.7.0"
+
+// lssExportRun is a LiveSplit .lss file. Unlike lssRun, which only reads
+// what gets imported, it has every element LiveSplit expects to find, even
+// when empty.
+type lssExportRun struct {
+	XMLName              xml.Name          `xml:"Run"`
+	Version              string            `xml:"version,attr"`
+	GameIcon             string            `xml:"GameIcon"`
+	GameName             string            `xml:"GameName"`
+	CategoryName         string            `xml:"CategoryName"`
+	Metadata             lssExportMetadata `xml:"Metadata"`
+	Offset               string            `xml:"Offset"`
+	AttemptCount         int               `xml:"AttemptCount"`
+	AttemptHistory       lssExportAttempts `xml:"AttemptHistory"`
+	Segments             lssExportSegments `xml:"Segments"`
+	AutoSplitterSettings string            `xml:"AutoSplitterSettings"`
+}
+
+type lssExportMetadata struct {
+	Run       lssExportMetadataRun `xml:"Run"`
+	Platform  lssExportPlatform    `xml:"Platform"`
+	Region    string               `xml:"Region"`
+	Variables string               `xml:"Variables"`
+}
+
+type lssExportMetadataRun struct {
+	ID string `xml:"id,attr"`
+}
+
+type lssExportPlatform struct {
+	UsesEmulator string `xml:"usesEmulator,attr"`
+}
+
+type lssExportAttempts struct {
+	Attempts []lssExportAttempt `xml:"Attempt"`
+}
+
+// lssExportAttempt is one attempt of the history, with its final time
+type lssExportAttempt struct {
+	ID       int    `xml:"id,attr"`
+	Started  string `xml:"started,attr,omitempty"`
+	Ended    string `xml:"ended,attr,omitempty"`
+	RealTime string `xml:"RealTime,omitempty"`
+}
+
+type lssExportSegments struct {
+	Segments []lssExportSegment `xml:"Segment"`
+}
+
+type lssExportSegment struct {
+	Name            string             `xml:"Name"`
+	Icon            string             `xml:"Icon"`
+	SplitTimes      lssExportSplits    `xml:"SplitTimes"`
+	BestSegmentTime lssExportTime      `xml:"BestSegmentTime"`
+	SegmentHistory  lssExportHistories `xml:"SegmentHistory"`
+}
+
+type lssExportSplits struct {
+	SplitTimes []lssExportSplitTime `xml:"SplitTime"`
+}
+
+// lssExportSplitTime is a segment's cumulative time in a comparison
+type lssExportSplitTime struct {
+	Name     string `xml:"name,attr"`
+	RealTime string `xml:"RealTime,omitempty"`
+}
+
+// lssExportTime is a time that is left empty when unknown
+type lssExportTime struct {
+	RealTime string `xml:"RealTime,omitempty"`
+}
+
+type lssExportHistories struct {
+	Times []lssExportHistoryTime `xml:"Time"`
+}
+
+// lssExportHistoryTime is a segment's own time in the attempt with this ID
+type lssExportHistoryTime struct {
+	ID       int    `xml:"id,attr"`
+	RealTime string `xml:"RealTime"`
+}
+
+// ExportToLSS writes the config, split names, golds and PB to w as a
+// LiveSplit .lss file, using real time. The PB is the only attempt in the
+// history. Subsplits are written with LiveSplit's "-" prefix, and the last
+// one of each group is named after itself, as the group name isn't stored.
+func (rm *RunManager) ExportToLSS(w io.Writer) error {
+	rm.mu.RLock()
+	snapshot := rm.exportSnapshot()
+	rm.mu.RUnlock()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write LSS: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(snapshot.lssRun()); err != nil {
+		return fmt.Errorf("failed to write LSS: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("failed to write LSS: %w", err)
+	}
+	return nil
+}
+
+// lssRun converts the snapshot into a LiveSplit run
+func (s exportSnapshot) lssRun() lssExportRun {
+	run := lssExportRun{
+		Version:      lssExportVersion,
+		GameName:     s.title,
+		CategoryName: s.category,
+		Metadata:     lssExportMetadata{Platform: lssExportPlatform{UsesEmulator: "False"}},
+		Offset:       formatLSSTime(0),
+		AttemptCount: s.attempts,
+	}
+
+	if s.pb != nil {
+		attempt := lssExportAttempt{
+			ID:       s.pb.AttemptNum,
+			RealTime: formatLSSTime(s.pb.TimeSince(len(s.pb.Splits) - 1)),
+		}
+		if !s.pb.StartTime.IsZero() {
+			attempt.Started = formatLSSDate(s.pb.StartTime)
+		}
+		if !s.pb.EndTime.IsZero() {
+			attempt.Ended = formatLSSDate(s.pb.EndTime)
+		}
+		run.AttemptHistory.Attempts = append(run.AttemptHistory.Attempts, attempt)
+	}
+
+	for i := range s.splits {
+		segment := lssExportSegment{Name: lssExportName(s.splits, i)}
+
+		pbTime := lssExportSplitTime{Name: "Personal Best"}
+		if s.pb != nil && i < len(s.pb.Splits) {
+			// A skipped split has no time of its own, and no split time
+			if d := s.pb.Splits[i].Duration; d > 0 {
+				pbTime.RealTime = formatLSSTime(s.pb.TimeSince(i))
+				segment.SegmentHistory.Times = append(segment.SegmentHistory.Times, lssExportHistoryTime{
+					ID:       s.pb.AttemptNum,
+					RealTime: formatLSSTime(d),
+				})
+			}
+		}
+		segment.SplitTimes.SplitTimes = []lssExportSplitTime{pbTime}
+
+		if i < len(s.golds) && s.golds[i] > 0 {
+			segment.BestSegmentTime.RealTime = formatLSSTime(s.golds[i])
+		}
+		run.Segments.Segments = append(run.Segments.Segments, segment)
+	}
+	return run
+}
+
+// lssExportName returns the LiveSplit name of split i: subsplits start with
+// "-", except the last of their group, which is named "{Group} Name"
+func lssExportName(splits []SplitNode, i int) string {
+	name := splits[i].Name
+	if splits[i].Depth == 0 {
+		return name
+	}
+	if i+1 < len(splits) && splits[i+1].Depth != 0 {
+		return "-" + name
+	}
+	return fmt.Sprintf("{%s} %s", name, name)
+}
+
+// formatLSSTime formats d the way LiveSplit does, as "hh:mm:ss.fffffff"
+func formatLSSTime(d time.Duration) string {
+	ticks := d / 100 // LiveSplit counts in 100ns ticks
+	return fmt.Sprintf("%02d:%02d:%02d.%07d",
+		int(d/time.Hour), int(d/time.Minute)%60, int(d/time.Second)%60, int(ticks%10_000_000))
+}
+
+// formatLSSDate formats t as a LiveSplit attempt date, in UTC
+func formatLSSDate(t time.Time) string {
+	return t.UTC().Format("01/02/2006 15:04:05")
+}
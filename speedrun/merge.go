@@ -0,0 +1,333 @@
+package speedrun
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// mergedRun holds a run read from another database along with its splits
+type mergedRun struct {
+	title      string
+	category   string
+	startTime  string
+	endTime    string
+	completed  bool
+	attemptNum int
+	notes      sql.NullString
+	archived   bool
+	splits     []int64
+}
+
+// MergeDatabase appends all runs and splits from another database file into
+// this one. Runs get new IDs, and attempt numbers that follow the current
+// ones. The other database must use the same split names, in the same order.
+// Its attempt and completed counters are added to this one's, so don't also
+// call MergeSplitNamesFrom for it. The PB only changes if a merged run beats
+// it, so a PB picked with SaveAsPB stays otherwise. It returns
+// ErrRunInProgress while a run is going.
+func (rm *RunManager) MergeDatabase(otherPath string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.isRunning {
+		return ErrRunInProgress
+	}
+	info, err := os.Stat(otherPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database to merge: %w", err)
+	}
+	if same, err := rm.isDatabaseFile(info); err != nil {
+		return err
+	} else if same {
+		return fmt.Errorf("cannot merge %s into itself", otherPath)
+	}
+
+	other, err := sql.Open("sqlite3", otherPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database to merge: %w", err)
+	}
+	defer other.Close()
+
+	// Make sure both databases track the same splits
+	rows, err := other.Query("SELECT name FROM split_names ORDER BY display_order")
+	if err != nil {
+		return fmt.Errorf("error loading split names to merge: %w", err)
+	}
+	var otherNames []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning split name: %w", err)
+		}
+		otherNames = append(otherNames, name)
+	}
+	rows.Close()
+
+	if err := rm.checkSplitLayout(otherNames); err != nil {
+		return err
+	}
+
+	var otherAttempts, otherCompleted int
+	err = other.QueryRow("SELECT attempts, completed FROM config WHERE id = 1").Scan(&otherAttempts, &otherCompleted)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error loading config to merge: %w", err)
+	}
+
+	// Databases from before notes and archiving lack those columns
+	notesColumn, err := optionalColumn(other, "runs", "notes", "NULL")
+	if err != nil {
+		return err
+	}
+	archivedColumn, err := optionalColumn(other, "runs", "archived", "0")
+	if err != nil {
+		return err
+	}
+
+	// Read every run and its splits from the other database
+	rows, err = other.Query(fmt.Sprintf(`
+		SELECT id, title, category, start_time, end_time, completed, attempt_num, %s, COALESCE(%s, 0)
+		FROM runs
+		ORDER BY id
+	`, notesColumn, archivedColumn))
+	if err != nil {
+		return fmt.Errorf("error loading runs to merge: %w", err)
+	}
+	var ids []int64
+	var runs []*mergedRun
+	maxAttempt := 0
+	for rows.Next() {
+		var id int64
+		var endTime sql.NullString
+		r := &mergedRun{}
+		if err := rows.Scan(&id, &r.title, &r.category, &r.startTime, &endTime, &r.completed, &r.attemptNum,
+			&r.notes, &r.archived); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning run to merge: %w", err)
+		}
+		r.endTime = endTime.String
+		ids = append(ids, id)
+		runs = append(runs, r)
+		maxAttempt = max(maxAttempt, r.attemptNum)
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		splitRows, err := other.Query(`
+			SELECT duration_ns
+			FROM splits
+			WHERE run_id = ?
+			ORDER BY split_index
+		`, id)
+		if err != nil {
+			return fmt.Errorf("error loading splits to merge: %w", err)
+		}
+		for splitRows.Next() {
+			var durationNs int64
+			if err := splitRows.Scan(&durationNs); err != nil {
+				splitRows.Close()
+				return fmt.Errorf("error scanning split to merge: %w", err)
+			}
+			runs[i].splits = append(runs[i].splits, durationNs)
+		}
+		splitRows.Close()
+	}
+
+	tx, err := rm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Merged attempts are numbered after every attempt of this database,
+	// keeping their order and gaps
+	var firstAttempt int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(attempt_num), 0) FROM runs").Scan(&firstAttempt); err != nil {
+		return fmt.Errorf("error finding last attempt: %w", err)
+	}
+	firstAttempt = max(firstAttempt, rm.attempts)
+
+	var mergedIDs []int64
+	for _, r := range runs {
+		result, err := tx.Exec(`
+			INSERT INTO runs
+			(title, category, start_time, end_time, completed, is_pb, attempt_num, notes, archived)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			r.title, r.category, r.startTime, r.endTime,
+			sqlite3Bool(r.completed), sqlite3Bool(false), firstAttempt+r.attemptNum,
+			r.notes, sqlite3Bool(r.archived),
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting merged run: %w", err)
+		}
+
+		runID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("error getting last insert ID: %w", err)
+		}
+		mergedIDs = append(mergedIDs, runID)
+
+		for i, durationNs := range r.splits {
+			if i >= rm.numSplits {
+				break
+			}
+			_, err = tx.Exec(`
+				INSERT INTO splits (run_id, split_index, split_name, duration_ns)
+				VALUES (?, ?, ?, ?)
+			`, runID, i, rm.splitNames[i], durationNs)
+			if err != nil {
+				return fmt.Errorf("error inserting merged split: %w", err)
+			}
+		}
+	}
+
+	attempts := firstAttempt + max(otherAttempts, maxAttempt)
+	completed := rm.completedRuns + otherCompleted
+	_, err = tx.Exec("UPDATE config SET attempts = ?, completed = ? WHERE id = 1", attempts, completed)
+	if err != nil {
+		return fmt.Errorf("error updating config: %w", err)
+	}
+
+	if len(mergedIDs) > 0 {
+		if err := rm.mergePB(tx, mergedIDs[0]); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	rm.attempts = attempts
+	rm.completedRuns = completed
+
+	// Reload PB and golds so the merged history is reflected
+	rm.pb, err = loadPersonalBest(rm.db, rm.category)
+	if err != nil {
+		return fmt.Errorf("failed to reload PB after merge: %w", err)
+	}
+	if err := rm.computeBestSegments(); err != nil {
+		return fmt.Errorf("failed to compute best segments after merge: %w", err)
+	}
+
+	return nil
+}
+
+// mergePB makes the fastest completed and unarchived run merged in with no
+// skipped splits the PB, if it beats the current one. Merged runs have IDs from firstID.
+func (rm *RunManager) mergePB(tx *sql.Tx, firstID int64) error {
+	var pbID, totalNs int64
+	err := tx.QueryRow(`
+		SELECT runs.id, SUM(splits.duration_ns) AS total
+		FROM runs
+		JOIN splits ON splits.run_id = runs.id
+		WHERE runs.id >= ? AND runs.completed = 1 AND runs.archived = 0 AND runs.category = ?
+		GROUP BY runs.id
+		HAVING COUNT(splits.id) = ? AND MIN(splits.duration_ns) > 0
+		ORDER BY total, runs.id
+		LIMIT 1
+	`, firstID, rm.category, rm.numSplits).Scan(&pbID, &totalNs)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error finding fastest merged run: %w", err)
+	}
+	if rm.pb != nil && time.Duration(totalNs) >= rm.pb.PBTime {
+		return nil
+	}
+
+	if _, err := tx.Exec("UPDATE runs SET is_pb = 0 WHERE is_pb = 1 AND category = ?", rm.category); err != nil {
+		return fmt.Errorf("error resetting previous PB: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE runs SET is_pb = 1, pb_time_ns = ? WHERE id = ?", totalNs, pbID); err != nil {
+		return fmt.Errorf("error setting new PB: %w", err)
+	}
+	return nil
+}
+
+// isDatabaseFile reports whether info is the file of rm's own database
+func (rm *RunManager) isDatabaseFile(info os.FileInfo) (bool, error) {
+	rows, err := rm.db.Query("PRAGMA database_list")
+	if err != nil {
+		return false, fmt.Errorf("error listing databases: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return false, fmt.Errorf("error scanning database list: %w", err)
+		}
+		// In-memory databases have no file
+		if name != "main" || file == "" {
+			continue
+		}
+		own, err := os.Stat(file)
+		if err != nil {
+			return false, fmt.Errorf("failed to stat database: %w", err)
+		}
+		return os.SameFile(info, own), nil
+	}
+	return false, rows.Err()
+}
+
+// optionalColumn returns column if table has it, and fallback otherwise, for
+// reading databases that were never migrated
+func optionalColumn(db *sql.DB, table, column, fallback string) (string, error) {
+	exists, err := columnExists(db, table, column)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return fallback, nil
+	}
+	return column, nil
+}
+
+// MergeSplitNamesFrom folds another RunManager's attempt and completed counters
+// into this one. Both must use the same split names. Runs are not copied.
+func (rm *RunManager) MergeSplitNamesFrom(source *RunManager) error {
+	// Copy what's needed from source first, so the two locks are never held
+	// together
+	source.mu.RLock()
+	names := append([]string(nil), source.splitNames...)
+	sourceAttempts, sourceCompleted := source.attempts, source.completedRuns
+	source.mu.RUnlock()
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if err := rm.checkSplitLayout(names); err != nil {
+		return err
+	}
+
+	attempts := rm.attempts + sourceAttempts
+	completed := rm.completedRuns + sourceCompleted
+
+	_, err := rm.db.Exec("UPDATE config SET attempts = ?, completed = ? WHERE id = 1",
+		attempts, completed)
+	if err != nil {
+		return fmt.Errorf("error updating config: %w", err)
+	}
+
+	rm.attempts = attempts
+	rm.completedRuns = completed
+	return nil
+}
+
+// checkSplitLayout returns an error unless names matches the current split names
+func (rm *RunManager) checkSplitLayout(names []string) error {
+	if len(names) != rm.numSplits {
+		return fmt.Errorf("cannot merge: split layout mismatch (%d splits vs %d)", len(names), rm.numSplits)
+	}
+	for i, name := range names {
+		if name != rm.splitNames[i] {
+			return fmt.Errorf("cannot merge: split %d is %q, expected %q", i, name, rm.splitNames[i])
+		}
+	}
+	return nil
+}
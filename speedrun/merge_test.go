@@ -0,0 +1,126 @@
+package speedrun
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMergeDatabase(t *testing.T) {
+	dir := t.TempDir()
+	rm := openTestRunManager(t, filepath.Join(dir, "a.db"))
+	if err := rm.SeedDemoData(); err != nil {
+		t.Fatalf("SeedDemoData: %v", err)
+	}
+	otherPath := filepath.Join(dir, "b.db")
+	other := openTestRunManager(t, otherPath)
+	if err := other.SeedDemoData(); err != nil {
+		t.Fatalf("SeedDemoData: %v", err)
+	}
+	// A run faster than both seeded PBs, and a reset with a reason
+	fast := make([]time.Duration, len(demoSplits))
+	var fastTotal time.Duration
+	for i, split := range demoSplits {
+		fast[i] = split.duration - 5*time.Second
+		fastTotal += fast[i]
+	}
+	recordRun(t, other, fast...)
+	other.mu.Lock()
+	other.startRun()
+	other.splits = append(other.splits, time.Minute)
+	other.mu.Unlock()
+	if err := other.ResetRunWithReason("controller died"); err != nil {
+		t.Fatalf("ResetRunWithReason: %v", err)
+	}
+
+	attempts, completed := rm.GetAttempts(), rm.GetCompletedRuns()
+	otherAttempts, otherCompleted := other.GetAttempts(), other.GetCompletedRuns()
+	if err := other.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := rm.MergeDatabase(otherPath); err != nil {
+		t.Fatalf("MergeDatabase: %v", err)
+	}
+
+	if got, want := rm.GetAttempts(), attempts+otherAttempts; got != want {
+		t.Errorf("attempts = %d, want %d", got, want)
+	}
+	if got, want := rm.GetCompletedRuns(), completed+otherCompleted; got != want {
+		t.Errorf("completed = %d, want %d", got, want)
+	}
+	if pb := rm.GetPersonalBest(); pb == nil || pb.PBTime != fastTotal {
+		t.Errorf("PB = %+v, want the merged run of %v", pb, fastTotal)
+	}
+
+	// Counters are stored too, not just kept in memory
+	reopened := openTestRunManager(t, filepath.Join(dir, "a.db"))
+	if got, want := reopened.GetAttempts(), attempts+otherAttempts; got != want {
+		t.Errorf("stored attempts = %d, want %d", got, want)
+	}
+
+	runs, err := rm.GetRunHistory(100, 0)
+	if err != nil {
+		t.Fatalf("GetRunHistory: %v", err)
+	}
+	if len(runs) != attempts+otherAttempts {
+		t.Errorf("got %d runs, want %d", len(runs), attempts+otherAttempts)
+	}
+	seen := make(map[int]bool)
+	for _, run := range runs {
+		if seen[run.AttemptNum] {
+			t.Errorf("attempt number %d used twice", run.AttemptNum)
+		}
+		seen[run.AttemptNum] = true
+	}
+
+	var notes string
+	if err := rm.db.QueryRow("SELECT notes FROM runs WHERE notes IS NOT NULL").Scan(&notes); err != nil {
+		t.Fatalf("loading merged notes: %v", err)
+	}
+	if notes != "controller died" {
+		t.Errorf("notes = %q, want %q", notes, "controller died")
+	}
+}
+
+func TestMergeDatabaseKeepsFasterPB(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"A", "B"}
+	rm := openTestRunManager(t, filepath.Join(dir, "a.db"), names...)
+	recordRun(t, rm, 10*time.Second, 10*time.Second)
+	otherPath := filepath.Join(dir, "b.db")
+	other := openTestRunManager(t, otherPath, names...)
+	recordRun(t, other, 15*time.Second, 15*time.Second)
+	other.Close()
+
+	if err := rm.MergeDatabase(otherPath); err != nil {
+		t.Fatalf("MergeDatabase: %v", err)
+	}
+	if pb := rm.GetPersonalBest(); pb == nil || pb.PBTime != 20*time.Second {
+		t.Errorf("PB = %+v, want the local 20s run", pb)
+	}
+}
+
+func TestMergeDatabaseRefuses(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.db")
+	rm := openTestRunManager(t, path, "A", "B")
+
+	if err := rm.MergeDatabase(path); err == nil {
+		t.Error("merging a database into itself succeeded")
+	}
+
+	mismatchPath := filepath.Join(dir, "mismatch.db")
+	openTestRunManager(t, mismatchPath, "A", "C").Close()
+	if err := rm.MergeDatabase(mismatchPath); err == nil {
+		t.Error("merging a different split layout succeeded")
+	}
+
+	otherPath := filepath.Join(dir, "b.db")
+	openTestRunManager(t, otherPath, "A", "B").Close()
+	rm.StartRun()
+	if err := rm.MergeDatabase(otherPath); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("MergeDatabase during a run = %v, want ErrRunInProgress", err)
+	}
+}
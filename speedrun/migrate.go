@@ -0,0 +1,149 @@
+package speedrun
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrMigrationRequired is returned when the database schema is out of date and
+// automatic migration is turned off
+var ErrMigrationRequired = errors.New("database schema needs migrating")
+
+// migration adds a column that databases created by older versions lack
+type migration struct {
+	table      string
+	column     string
+	definition string
+}
+
+// migrations lists every schema change since the first release, oldest first.
+// Applying migrations[i] takes the database to schema version i+1, which is
+// stored in the schema_version table. New tables don't need a migration:
+// initDatabase creates any that are missing.
+var migrations = []migration{
+	{"split_names", "subsplit_depth", "INTEGER DEFAULT 0"},
+	{"runs", "notes", "TEXT"},
+	{"runs", "archived", "BOOLEAN DEFAULT 0"},
+	{"runs", "pb_time_ns", "INTEGER"},
+	{"splits", "note", "TEXT"},
+	{"runs", "game_version", "TEXT"},
+}
+
+// Migrate applies any pending schema migrations and loads the run data. Call
+// it after NewRunManagerWithOptions returns ErrMigrationRequired.
+func (rm *RunManager) Migrate() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if err := applyMigrations(rm.db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return rm.load()
+}
+
+// pendingMigrations returns the migrations the database hasn't had yet
+func pendingMigrations(db *sql.DB) ([]migration, error) {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	return migrations[version:], nil
+}
+
+// applyMigrations applies each pending migration in its own transaction,
+// bumping the schema version along with it
+func applyMigrations(db *sql.DB) error {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		if err := applyMigration(db, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMigration applies migrations[version], taking the database to
+// version+1
+func applyMigration(db *sql.DB, version int) error {
+	m := migrations[version]
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition))
+	if err != nil {
+		return fmt.Errorf("error adding %s.%s column: %w", m.table, m.column, err)
+	}
+	if _, err := tx.Exec("UPDATE schema_version SET version = ? WHERE id = 1", version+1); err != nil {
+		return fmt.Errorf("error updating schema version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the schema version of the database. Databases from
+// before schema versioning have none stored, so it is worked out from the
+// columns they already have and stored for next time.
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("SELECT version FROM schema_version WHERE id = 1").Scan(&version)
+	if err == sql.ErrNoRows {
+		if version, err = legacySchemaVersion(db); err != nil {
+			return 0, err
+		}
+		if _, err := db.Exec("INSERT INTO schema_version (id, version) VALUES (1, ?)", version); err != nil {
+			return 0, fmt.Errorf("error storing schema version: %w", err)
+		}
+		return version, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return 0, fmt.Errorf("database schema version %d is newer than this version of the timer supports (%d)",
+			version, len(migrations))
+	}
+	return version, nil
+}
+
+// legacySchemaVersion works out the version of an unversioned database. The
+// old migration code added the columns in order, so the version is the
+// number of migrations before the first missing column.
+func legacySchemaVersion(db *sql.DB) (int, error) {
+	for i, m := range migrations {
+		exists, err := columnExists(db, m.table, m.column)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return i, nil
+		}
+	}
+	return len(migrations), nil
+}
+
+// columnExists reports whether table has a column with the given name
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("error reading %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return false, fmt.Errorf("error scanning %s columns: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -0,0 +1,49 @@
+package speedrun
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNoSavedRun is returned when changing the last saved run before any run
+// has been saved
+var ErrNoSavedRun = errors.New("no run has been saved yet")
+
+// SetSplitNote attaches a note to split index of the run saved last, e.g.
+// "missed the cycle". An empty note removes it.
+func (rm *RunManager) SetSplitNote(index int, note string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.currentRunID == 0 {
+		return ErrNoSavedRun
+	}
+	result, err := rm.db.Exec("UPDATE splits SET note = ? WHERE run_id = ? AND split_index = ?",
+		sql.NullString{String: note, Valid: note != ""}, rm.currentRunID, index)
+	if err != nil {
+		return fmt.Errorf("error setting split note: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error setting split note: %w", err)
+	}
+	if n == 0 {
+		return ErrIndexOutOfRange
+	}
+	return nil
+}
+
+// SetGameVersion records the version of the game the run saved last was
+// played on, e.g. "1.0 JP". An empty version removes it.
+func (rm *RunManager) SetGameVersion(version string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.currentRunID == 0 {
+		return ErrNoSavedRun
+	}
+	_, err := rm.db.Exec("UPDATE runs SET game_version = ? WHERE id = ?", sql.NullString{String: version, Valid: version != ""}, rm.currentRunID)
+	if err != nil {
+		return fmt.Errorf("error setting game version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package speedrun
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSplitNoteAndGameVersion(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	if err := rm.SetSplitNote(0, "early"); !errors.Is(err, ErrNoSavedRun) {
+		t.Errorf("SetSplitNote before any run = %v, want ErrNoSavedRun", err)
+	}
+	if err := rm.SetGameVersion("1.0"); !errors.Is(err, ErrNoSavedRun) {
+		t.Errorf("SetGameVersion before any run = %v, want ErrNoSavedRun", err)
+	}
+
+	rm.mu.Lock()
+	rm.startRun()
+	rm.splits = append(rm.splits, 10*time.Second, 20*time.Second)
+	if err := rm.saveRun(true, ""); err != nil {
+		t.Fatalf("saveRun: %v", err)
+	}
+	rm.mu.Unlock()
+
+	if err := rm.SetSplitNote(1, "missed the cycle"); err != nil {
+		t.Fatalf("SetSplitNote: %v", err)
+	}
+	if err := rm.SetSplitNote(2, "no such split"); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("SetSplitNote(2) = %v, want ErrIndexOutOfRange", err)
+	}
+	if err := rm.SetGameVersion("1.0 JP"); err != nil {
+		t.Fatalf("SetGameVersion: %v", err)
+	}
+
+	runs, err := rm.ListRuns(1, 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListRuns = %d runs, %v", len(runs), err)
+	}
+	run := runs[0]
+	if run.GameVersion != "1.0 JP" {
+		t.Errorf("game version = %q, want %q", run.GameVersion, "1.0 JP")
+	}
+	if run.Splits[0].Note != "" || run.Splits[1].Note != "missed the cycle" {
+		t.Errorf("notes = %q, %q, want only B's", run.Splits[0].Note, run.Splits[1].Note)
+	}
+}
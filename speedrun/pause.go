@@ -0,0 +1,67 @@
+package speedrun
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPaused is returned when splitting, skipping or undoing while the run is
+// paused
+var ErrPaused = errors.New("run is paused")
+
+// PauseRun stops the clock of the running run until ResumeRun is called. The run
+// still counts as running. Splits, skips and undos are refused while paused;
+// reset still works.
+func (rm *RunManager) PauseRun() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if !rm.isRunning {
+		return errors.New("cannot pause: no run in progress")
+	}
+	if rm.isPaused() {
+		return errors.New("run is already paused")
+	}
+	rm.pausedAt = time.Now()
+	return nil
+}
+
+// ResumeRun restarts the clock of a paused run. The paused time counts towards
+// neither the run nor the current split.
+func (rm *RunManager) ResumeRun() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if !rm.isPaused() {
+		return errors.New("run is not paused")
+	}
+	paused := time.Since(rm.pausedAt)
+	rm.pausedTotal += paused
+	rm.splitPaused += paused
+	rm.pausedAt = time.Time{}
+	return nil
+}
+
+// IsPaused returns whether the run is paused
+func (rm *RunManager) IsPaused() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.isPaused()
+}
+
+func (rm *RunManager) isPaused() bool {
+	return !rm.pausedAt.IsZero()
+}
+
+// now is the current time on the run's clock, which stands still while paused
+func (rm *RunManager) now() time.Time {
+	if rm.isPaused() {
+		return rm.pausedAt
+	}
+	return time.Now()
+}
+
+// clearPause forgets all paused time, for a new or reset run
+func (rm *RunManager) clearPause() {
+	rm.pausedAt = time.Time{}
+	rm.pausedTotal = 0
+	rm.splitPaused = 0
+}
@@ -0,0 +1,48 @@
+package speedrun
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPauseRun(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B")
+	if err := rm.PauseRun(); err == nil {
+		t.Error("PauseRun without a run succeeded")
+	}
+
+	rm.StartRun()
+	if err := rm.PauseRun(); err != nil {
+		t.Fatalf("PauseRun: %v", err)
+	}
+	if err := rm.PauseRun(); err == nil {
+		t.Error("pausing twice succeeded")
+	}
+	if !rm.IsPaused() || !rm.IsRunning() {
+		t.Fatal("a paused run should be paused and still running")
+	}
+	frozen := rm.GetCurrentTime()
+	time.Sleep(50 * time.Millisecond)
+	if got := rm.GetCurrentTime(); got != frozen {
+		t.Errorf("the clock moved from %v to %v while paused", frozen, got)
+	}
+	if _, err := rm.Split(); !errors.Is(err, ErrPaused) {
+		t.Errorf("Split while paused = %v, want ErrPaused", err)
+	}
+
+	if err := rm.ResumeRun(); err != nil {
+		t.Fatalf("ResumeRun: %v", err)
+	}
+	if err := rm.ResumeRun(); err == nil {
+		t.Error("resuming a run that isn't paused succeeded")
+	}
+	// The paused time doesn't count towards the split
+	result, err := rm.Split()
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if result.SegmentTime >= 50*time.Millisecond {
+		t.Errorf("segment time = %v, want the 50ms paused left out", result.SegmentTime)
+	}
+}
@@ -0,0 +1,109 @@
+package speedrun
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetRunsBetweenAttempts returns the runs with attempt numbers from through
+// to, inclusive, with their splits, in attempt order
+func (rm *RunManager) GetRunsBetweenAttempts(from, to int) ([]*Run, error) {
+	var runs []*Run
+	err := rm.forEachRun("runs.attempt_num BETWEEN ? AND ?", []interface{}{from, to}, "runs.attempt_num, runs.id",
+		func(run *Run) error {
+			runs = append(runs, run)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// ListRuns returns up to limit runs, completed or not, with their splits,
+// newest first, after skipping the offset newest ones
+func (rm *RunManager) ListRuns(limit, offset int) ([]Run, error) {
+	rm.mu.RLock()
+	includeArchived := rm.includeArchived
+	rm.mu.RUnlock()
+
+	var runs []Run
+	where := `runs.id IN (
+		SELECT id FROM runs WHERE ? OR archived = 0 ORDER BY id DESC LIMIT ? OFFSET ?
+	)`
+	err := rm.forEachRun(where, []interface{}{sqlite3Bool(includeArchived), limit, offset}, "runs.id DESC",
+		func(run *Run) error {
+			runs = append(runs, *run)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetRunHistory returns the runs for the history screen, newest first: up
+// to limit of them after skipping the offset newest ones. It is the same as
+// ListRuns.
+func (rm *RunManager) GetRunHistory(limit, offset int) ([]Run, error) {
+	return rm.ListRuns(limit, offset)
+}
+
+// forEachRun calls fn with every run matching the SQL condition where, along
+// with its splits, ordered by orderBy. Runs are read one at a time, and
+// archived runs are skipped unless SetIncludeArchived is on.
+func (rm *RunManager) forEachRun(where string, args []interface{}, orderBy string, fn func(*Run) error) error {
+	rm.mu.RLock()
+	args = append(args, sqlite3Bool(rm.includeArchived))
+	rm.mu.RUnlock()
+	rows, err := rm.db.Query(fmt.Sprintf(`
+		SELECT runs.id, runs.title, runs.category, runs.start_time, runs.end_time,
+			runs.completed, runs.is_pb, runs.attempt_num, COALESCE(runs.game_version, ''),
+			splits.split_name, splits.duration_ns, COALESCE(splits.note, '')
+		FROM runs
+		LEFT JOIN splits ON splits.run_id = runs.id
+		WHERE %s AND (? OR runs.archived = 0)
+		ORDER BY %s, splits.split_index
+	`, where, orderBy), args...)
+	if err != nil {
+		return fmt.Errorf("error loading runs: %w", err)
+	}
+	defer rows.Close()
+
+	var run *Run
+	for rows.Next() {
+		var r Run
+		var startTime string
+		var endTime, splitName sql.NullString
+		var durationNs sql.NullInt64
+		var note string
+		if err := rows.Scan(&r.ID, &r.Title, &r.Category, &startTime, &endTime,
+			&r.Completed, &r.IsPB, &r.AttemptNum, &r.GameVersion, &splitName, &durationNs, &note); err != nil {
+			return fmt.Errorf("error scanning run: %w", err)
+		}
+
+		if run == nil || run.ID != r.ID {
+			if run != nil {
+				if err := fn(run); err != nil {
+					return err
+				}
+			}
+			r.StartTime, _ = time.Parse(time.RFC3339, startTime)
+			r.EndTime, _ = time.Parse(time.RFC3339, endTime.String)
+			run = &r
+		}
+		if splitName.Valid {
+			run.appendSplit(splitName.String, time.Duration(durationNs.Int64))
+			run.Splits[len(run.Splits)-1].Note = note
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error loading runs: %w", err)
+	}
+
+	if run != nil {
+		return fn(run)
+	}
+	return nil
+}
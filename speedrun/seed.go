@@ -0,0 +1,156 @@
+package speedrun
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// demoSplits are the split names and rough segment times used by SeedDemoData
+var demoSplits = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"Act 1 ~ The Barbarian", 49 * time.Second},
+	{"Act 2 ~ Bomberhead", 1*time.Minute + 57*time.Second},
+	{"Act 3 ~ Basaquer", 1*time.Minute + 33*time.Second},
+	{"Act 4 ~ Kelbeross", 2*time.Minute + 20*time.Second},
+	{"Act 5 ~ Bloody Malth", 3*time.Minute + 4*time.Second},
+	{"Act 6 ~ The Masked Devil", 2*time.Minute + 48*time.Second},
+	{"Act 6 ~ Jaquio", 28 * time.Second},
+	{"Act 6 ~ The Demon", 31 * time.Second},
+}
+
+const demoRuns = 10
+
+// SeedDemoData fills an empty database with a sample game, a handful of
+// completed and reset runs, a PB and golds. It refuses to touch a database
+// that already has runs so real data is never clobbered.
+func (rm *RunManager) SeedDemoData() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	var runCount int
+	if err := rm.db.QueryRow("SELECT COUNT(*) FROM runs").Scan(&runCount); err != nil {
+		return fmt.Errorf("error counting runs: %w", err)
+	}
+	if runCount > 0 {
+		return fmt.Errorf("cannot seed: database already has %d runs", runCount)
+	}
+
+	title := "Ninja Gaiden (NES)"
+	category := "Any%"
+	names := make([]string, len(demoSplits))
+	for i, split := range demoSplits {
+		names[i] = split.name
+	}
+
+	// Fixed seed so screenshots are reproducible
+	rng := rand.New(rand.NewSource(1))
+
+	tx, err := rm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM split_names")
+	if err != nil {
+		return fmt.Errorf("error deleting existing split names: %w", err)
+	}
+	for i, name := range names {
+		_, err = tx.Exec("INSERT INTO split_names (name, display_order) VALUES (?, ?)", name, i)
+		if err != nil {
+			return fmt.Errorf("error inserting split name: %w", err)
+		}
+	}
+
+	startTime := time.Now().Add(-time.Duration(demoRuns) * 24 * time.Hour)
+	attempts, completed := 0, 0
+	var pbID int64
+	var pbTotal time.Duration
+
+	for n := 0; n < demoRuns; n++ {
+		attempts++
+		// Two of every five attempts are reset partway through the run
+		isCompleted := n%5 != 1 && n%5 != 3
+		numSplits := len(demoSplits)
+		if !isCompleted {
+			numSplits = 1 + rng.Intn(len(demoSplits)-1)
+		} else {
+			completed++
+		}
+
+		var total time.Duration
+		segments := make([]time.Duration, numSplits)
+		for i := range segments {
+			// Up to 10% slower than the reference time, improving over attempts
+			base := demoSplits[i].duration
+			jitter := time.Duration(rng.Int63n(int64(base / 10)))
+			segments[i] = base + jitter - time.Duration(n)*base/200
+			total += segments[i]
+		}
+
+		endTime := startTime.Add(total)
+		result, err := tx.Exec(`
+			INSERT INTO runs
+			(title, category, start_time, end_time, completed, is_pb, attempt_num)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`,
+			title, category, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339),
+			sqlite3Bool(isCompleted), sqlite3Bool(false), attempts,
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting demo run: %w", err)
+		}
+
+		runID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("error getting last insert ID: %w", err)
+		}
+
+		for i, d := range segments {
+			_, err = tx.Exec(`
+				INSERT INTO splits (run_id, split_index, split_name, duration_ns)
+				VALUES (?, ?, ?, ?)
+			`, runID, i, names[i], d.Nanoseconds())
+			if err != nil {
+				return fmt.Errorf("error inserting demo split: %w", err)
+			}
+		}
+
+		if isCompleted && (pbID == 0 || total < pbTotal) {
+			pbID, pbTotal = runID, total
+		}
+		startTime = startTime.Add(24 * time.Hour)
+	}
+
+	if _, err := tx.Exec("UPDATE runs SET is_pb = 1, pb_time_ns = ? WHERE id = ?", pbTotal.Nanoseconds(), pbID); err != nil {
+		return fmt.Errorf("error setting demo PB: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE config SET title = ?, category = ?, attempts = ?, completed = ? WHERE id = 1",
+		title, category, attempts, completed)
+	if err != nil {
+		return fmt.Errorf("error updating config: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	rm.title = title
+	rm.category = category
+	rm.attempts = attempts
+	rm.completedRuns = completed
+	rm.setSplitNames(names, nil)
+
+	rm.pb, err = loadPersonalBest(rm.db, rm.category)
+	if err != nil {
+		return fmt.Errorf("failed to reload PB after seeding: %w", err)
+	}
+	if err := rm.computeBestSegments(); err != nil {
+		return fmt.Errorf("failed to compute best segments after seeding: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package speedrun
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSeedDemoData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo.db")
+	rm := openTestRunManager(t, path)
+	if err := rm.SeedDemoData(); err != nil {
+		t.Fatalf("SeedDemoData: %v", err)
+	}
+	if err := rm.SeedDemoData(); err == nil {
+		t.Error("seeding a database that has runs succeeded")
+	}
+	rm.Close()
+
+	// A fresh manager loads the seeded PB and golds from the database
+	rm = openTestRunManager(t, path)
+	if got := len(rm.GetSplitNames()); got != len(demoSplits) {
+		t.Fatalf("got %d split names, want %d", got, len(demoSplits))
+	}
+	pb := rm.GetPersonalBest()
+	if pb == nil {
+		t.Fatal("no PB after seeding")
+	}
+	if len(pb.Splits) != len(demoSplits) || !pb.Completed || pb.PBTime <= 0 {
+		t.Errorf("PB has %d splits, completed %v, time %v", len(pb.Splits), pb.Completed, pb.PBTime)
+	}
+	golds := rm.GetGoldSegments()
+	if len(golds) != len(demoSplits) {
+		t.Fatalf("got %d golds, want %d", len(golds), len(demoSplits))
+	}
+	for i, gold := range golds {
+		if gold <= 0 || gold > pb.Splits[i].Duration {
+			t.Errorf("gold %d = %v, want a time no slower than the PB's %v", i, gold, pb.Splits[i].Duration)
+		}
+	}
+	if rm.GetAttempts() != demoRuns || rm.GetCompletedRuns() == 0 {
+		t.Errorf("attempts %d, completed %d, want %d attempts and some completed",
+			rm.GetAttempts(), rm.GetCompletedRuns(), demoRuns)
+	}
+}
@@ -0,0 +1,417 @@
+package speedrun
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OverlayState is the message sent to overlay clients. Times are in
+// milliseconds.
+type OverlayState struct {
+	CurrentTime       int64          `json:"currentTime"`
+	CurrentSplitIndex int            `json:"currentSplitIndex"`
+	Splits            []OverlaySplit `json:"splits"`
+	IsRunning         bool           `json:"isRunning"`
+	IsFinished        bool           `json:"isFinished"`
+}
+
+// OverlaySplit is one split row of an OverlayState. Elapsed is the run time
+// at the end of the split, and the diffs are null until they are known, e.g.
+// for splits not done yet or without a PB or gold to compare to.
+type OverlaySplit struct {
+	Name     string `json:"name"`
+	Elapsed  *int64 `json:"elapsed"`
+	DiffPB   *int64 `json:"diffPB"`
+	DiffGold *int64 `json:"diffGold"`
+}
+
+// Overlay converts the state into the message sent to overlay clients
+func (s StreamingState) Overlay() OverlayState {
+	state := OverlayState{
+		CurrentTime:       s.CurrentTime.Milliseconds(),
+		CurrentSplitIndex: s.CurrentSplit,
+		Splits:            make([]OverlaySplit, len(s.SplitNames)),
+		IsRunning:         s.IsRunning,
+		IsFinished:        s.IsCompleted,
+	}
+
+	var elapsed, pbElapsed time.Duration
+	for i, node := range s.SplitNames {
+		split := OverlaySplit{Name: node.Name}
+		if s.PB != nil && i < len(s.PB.Splits) {
+			pbElapsed += s.PB.Splits[i].Duration
+		}
+		// Skipped splits have no time of their own
+		if i < len(s.Splits) && s.Splits[i] > 0 {
+			elapsed += s.Splits[i]
+			split.Elapsed = millis(elapsed)
+			if s.PB != nil && i < len(s.PB.Splits) && s.PB.Splits[i].Duration > 0 {
+				split.DiffPB = millis(elapsed - pbElapsed)
+			}
+			if i < len(s.Golds) && s.Golds[i] > 0 && SegmentComparable(s.Splits, i) {
+				split.DiffGold = millis(s.Splits[i] - s.Golds[i])
+			}
+		}
+		state.Splits[i] = split
+	}
+	return state
+}
+
+func millis(d time.Duration) *int64 {
+	ms := d.Milliseconds()
+	return &ms
+}
+
+// websocketGUID is the fixed key suffix of the WebSocket handshake (RFC 6455)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// OverlayServer sends the timer state as JSON over WebSocket to any number
+// of clients, e.g. an OBS browser source. It only sends what it is given
+// with Broadcast, apart from one snapshot for each new client.
+type OverlayServer struct {
+	rm     *RunManager
+	server *http.Server
+	// allowedOrigins are the web pages, besides those served from this
+	// machine, that may read the timer
+	allowedOrigins []string
+
+	mu      sync.Mutex
+	clients map[*overlayClient]bool
+}
+
+// overlayClient is one WebSocket connection. send holds the next message to
+// write; a newer one replaces it if the client is slow.
+type overlayClient struct {
+	conn    net.Conn
+	send    chan []byte
+	writeMu sync.Mutex
+}
+
+// NewOverlayServer returns a server that will listen on addr, e.g.
+// "localhost:6060". rm is only used for the first message to a new client.
+// Browser pages may only connect if they are served from this machine or
+// their origin, e.g. "https://overlay.example", is in allowedOrigins.
+func NewOverlayServer(addr string, rm *RunManager, allowedOrigins ...string) *OverlayServer {
+	s := &OverlayServer{
+		rm:             rm,
+		allowedOrigins: allowedOrigins,
+		clients:        make(map[*overlayClient]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /state", s.serveState)
+	mux.HandleFunc("GET /splits/{index}", s.serveSplit)
+	mux.HandleFunc("/", s.serveWebSocket)
+	s.server = &http.Server{Addr: addr, Handler: s.checkOrigin(mux)}
+	return s
+}
+
+// checkOrigin refuses requests made by web pages from origins that may not
+// read the timer, so any site open in the browser can't
+func (s *OverlayServer) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.originAllowed(r.Header.Get("Origin")) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// originAllowed reports whether a page from origin may connect. Requests
+// with no Origin don't come from a web page.
+func (s *OverlayServer) originAllowed(origin string) bool {
+	if origin == "" || slices.Contains(s.allowedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// serveState replies with the same snapshot WebSocket clients are sent, taken
+// under a single lock by GetStreamingState
+func (s *OverlayServer) serveState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.rm.GetStreamingState().Overlay())
+}
+
+// OverlaySplitName is the reply to GET /splits/{index}
+type OverlaySplitName struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+}
+
+// serveSplit replies with the name of the split at the index in the path,
+// or 404 if there is no such split
+func (s *OverlayServer) serveSplit(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		http.Error(w, "invalid split index", http.StatusBadRequest)
+		return
+	}
+	name, err := s.rm.GetSplitNameByIndex(index)
+	if errors.Is(err, ErrIndexOutOfRange) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, OverlaySplitName{Index: index, Name: name})
+}
+
+// writeJSON replies with v encoded as JSON
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(msg)
+}
+
+// ListenAndServe serves clients until Close is called
+func (s *OverlayServer) ListenAndServe() error {
+	err := s.server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close stops the server and disconnects every client
+func (s *OverlayServer) Close() error {
+	err := s.server.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.conn.Close()
+		delete(s.clients, c)
+	}
+	return err
+}
+
+// HasClients reports whether any WebSocket client is connected, so callers
+// can skip building states nobody will get
+func (s *OverlayServer) HasClients() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients) > 0
+}
+
+// Broadcast sends state to every client. It never blocks: a client that
+// hasn't taken the previous state yet gets this one instead.
+func (s *OverlayServer) Broadcast(state OverlayState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 {
+		return
+	}
+	msg, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Error encoding overlay state: %v", err)
+		return
+	}
+	for c := range s.clients {
+		select {
+		case <-c.send:
+		default:
+		}
+		c.send <- msg
+	}
+}
+
+// serveWebSocket upgrades the request to a WebSocket and keeps sending
+// states to it until it goes away
+func (s *OverlayServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket connection", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		// The only version in RFC 6455
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("Error accepting overlay client: %v", err)
+		return
+	}
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	c := &overlayClient{conn: conn, send: make(chan []byte, 1)}
+	if msg, err := json.Marshal(s.rm.GetStreamingState().Overlay()); err == nil {
+		c.send <- msg
+	}
+	s.mu.Lock()
+	s.clients[c] = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.readFrames(rw.Reader)
+		close(done)
+	}()
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.writeFrame(0x1, msg); err != nil {
+				s.disconnect(c)
+				return
+			}
+		case <-done:
+			s.disconnect(c)
+			return
+		}
+	}
+}
+
+// disconnect forgets c and closes its connection
+func (s *OverlayServer) disconnect(c *overlayClient) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+	c.conn.Close()
+}
+
+// readFrames reads and drops the client's messages, answering pings, until
+// the client closes the connection
+func (c *overlayClient) readFrames(r *bufio.Reader) {
+	for {
+		opcode, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case 0x8: // close
+			c.writeFrame(0x8, nil)
+			return
+		case 0x9: // ping
+			if err := c.writeFrame(0xA, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeFrame writes a single unmasked frame, as servers must
+func (c *overlayClient) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// maxClientFrame is the largest frame accepted from a client. Clients have
+// no reason to send more than a ping.
+const maxClientFrame = 4096
+
+// readFrame reads one frame from a client and unmasks its payload
+func readFrame(r *bufio.Reader) (opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode = header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("unmasked frame from client")
+	}
+
+	n := uint64(header[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxClientFrame {
+		return 0, nil, fmt.Errorf("client frame too large: %d bytes", n)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// headerContains reports whether the comma-separated header name contains
+// token, ignoring case
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
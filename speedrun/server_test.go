@@ -0,0 +1,185 @@
+package speedrun
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOverlaySkippedSplit(t *testing.T) {
+	rm := newTestRunManager(t, "A", "B", "C")
+	recordRun(t, rm, 10*time.Second, 20*time.Second, 30*time.Second)
+
+	// B is skipped, so C's time also covers B
+	segments := []time.Duration{8 * time.Second, 0, 45 * time.Second}
+	rm.mu.Lock()
+	rm.startRun()
+	rm.splits = append(rm.splits, segments...)
+	rm.mu.Unlock()
+
+	want := []OverlaySplit{
+		{Name: "A", Elapsed: millis(8 * time.Second), DiffPB: millis(-2 * time.Second), DiffGold: millis(-2 * time.Second)},
+		{Name: "B"},
+		// The cumulative time carries over B, and C isn't a single segment
+		{Name: "C", Elapsed: millis(53 * time.Second), DiffPB: millis(-7 * time.Second)},
+	}
+	got := rm.GetStreamingState().Overlay().Splits
+	if len(got) != len(want) {
+		t.Fatalf("got %d splits, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Name != w.Name || !equalMillis(g.Elapsed, w.Elapsed) ||
+			!equalMillis(g.DiffPB, w.DiffPB) || !equalMillis(g.DiffGold, w.DiffGold) {
+			t.Errorf("split %d = %s %s %s %s, want %s %s %s %s", i,
+				g.Name, showMillis(g.Elapsed), showMillis(g.DiffPB), showMillis(g.DiffGold),
+				w.Name, showMillis(w.Elapsed), showMillis(w.DiffPB), showMillis(w.DiffGold))
+		}
+	}
+
+	// Saving the run only improves the gold of the split that was timed on
+	// its own
+	rm.mu.Lock()
+	if err := rm.saveRun(true, ""); err != nil {
+		t.Fatalf("saveRun: %v", err)
+	}
+	rm.mu.Unlock()
+	golds := rm.GetGoldSegments()
+	wantGolds := []time.Duration{8 * time.Second, 20 * time.Second, 30 * time.Second}
+	for i, w := range wantGolds {
+		if i >= len(golds) || golds[i] != w {
+			t.Errorf("golds = %v, want %v", golds, wantGolds)
+			break
+		}
+	}
+}
+
+func equalMillis(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func showMillis(ms *int64) string {
+	if ms == nil {
+		return "null"
+	}
+	return (time.Duration(*ms) * time.Millisecond).String()
+}
+
+func TestServeSplit(t *testing.T) {
+	rm := newTestRunManager(t, "Forest", "Castle")
+	handler := NewOverlayServer("localhost:0", rm).server.Handler
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantName   string
+	}{
+		{"/splits/0", http.StatusOK, "Forest"},
+		{"/splits/1", http.StatusOK, "Castle"},
+		{"/splits/2", http.StatusNotFound, ""},
+		{"/splits/-1", http.StatusNotFound, ""},
+		{"/splits/first", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s: status %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var split OverlaySplitName
+		if err := json.Unmarshal(rec.Body.Bytes(), &split); err != nil {
+			t.Errorf("GET %s: %v", tt.path, err)
+		} else if split.Name != tt.wantName {
+			t.Errorf("GET %s: name %q, want %q", tt.path, split.Name, tt.wantName)
+		}
+	}
+
+	// Other paths are still the WebSocket endpoint
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET / without an upgrade: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServeState(t *testing.T) {
+	rm := newTestRunManager(t, "Forest", "Castle")
+	handler := NewOverlayServer("localhost:0", rm).server.Handler
+	rm.StartRun()
+	if _, err := rm.Split(); err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/state", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /state: status %d", rec.Code)
+	}
+	var state OverlayState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("GET /state: %v", err)
+	}
+	if !state.IsRunning || state.CurrentSplitIndex != 1 || len(state.Splits) != 2 {
+		t.Errorf("state = %+v, want a running state on the second of 2 splits", state)
+	}
+	if state.Splits[0].Elapsed == nil || state.Splits[1].Elapsed != nil {
+		t.Errorf("elapsed = %v, %v, want only the first split timed", state.Splits[0].Elapsed, state.Splits[1].Elapsed)
+	}
+}
+
+func TestOverlayHandshakeChecks(t *testing.T) {
+	rm := newTestRunManager(t, "A")
+	srv := httptest.NewServer(NewOverlayServer("localhost:0", rm, "https://overlay.example").server.Handler)
+	defer srv.Close()
+
+	tests := []struct {
+		origin, version string
+		wantStatus      int
+	}{
+		{"", "13", http.StatusSwitchingProtocols},
+		{"http://localhost:8080", "13", http.StatusSwitchingProtocols},
+		{"https://overlay.example", "13", http.StatusSwitchingProtocols},
+		{"https://evil.example", "13", http.StatusForbidden},
+		{"null", "13", http.StatusForbidden},
+		{"", "8", http.StatusUpgradeRequired},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", tt.version)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("origin %q version %s: %v", tt.origin, tt.version, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("origin %q version %s: status %d, want %d", tt.origin, tt.version, resp.StatusCode, tt.wantStatus)
+		}
+	}
+
+	// Plain requests from foreign pages are refused too
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/state", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	srv.Config.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("GET /state from a foreign page: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
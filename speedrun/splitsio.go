@@ -0,0 +1,289 @@
+package speedrun
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const splitsIOBaseURL = "https://splits.io/api/v4"
+
+var splitsIOClient = &http.Client{Timeout: 30 * time.Second}
+
+// SplitsIOSchema is the response of the splits.io runs listing
+type SplitsIOSchema struct {
+	Runs []SplitsIORun `json:"runs"`
+}
+
+// SplitsIORun is a single run as returned by the splits.io API
+type SplitsIORun struct {
+	ID                 string            `json:"id"`
+	RealtimeDurationMS int64             `json:"realtime_duration_ms"`
+	Attempts           int               `json:"attempts"`
+	Game               *SplitsIOGame     `json:"game"`
+	Category           *SplitsIOCategory `json:"category"`
+	Segments           []SplitsIOSegment `json:"segments"`
+}
+
+// SplitsIOGame is the game a splits.io run belongs to
+type SplitsIOGame struct {
+	Name string `json:"name"`
+}
+
+// SplitsIOCategory is the category a splits.io run belongs to
+type SplitsIOCategory struct {
+	Name string `json:"name"`
+}
+
+// SplitsIOSegment is one segment of a splits.io run
+type SplitsIOSegment struct {
+	Name               string `json:"name"`
+	RealtimeEndMS      int64  `json:"realtime_end_ms"`
+	RealtimeDurationMS int64  `json:"realtime_duration_ms"`
+	RealtimeSkipped    bool   `json:"realtime_skipped"`
+}
+
+// ImportFromSplitsIO fetches the runs of a game category from splits.io and
+// imports the fastest one's splits as the PB, the same way ImportFromJSON does
+func (rm *RunManager) ImportFromSplitsIO(gameID, categoryID string) error {
+	listURL := fmt.Sprintf("%s/games/%s/categories/%s/runs", splitsIOBaseURL,
+		url.PathEscape(gameID), url.PathEscape(categoryID))
+
+	var list SplitsIOSchema
+	if err := fetchSplitsIO(listURL, &list); err != nil {
+		return err
+	}
+
+	var fastest *SplitsIORun
+	for i := range list.Runs {
+		run := &list.Runs[i]
+		if run.RealtimeDurationMS <= 0 {
+			continue
+		}
+		if fastest == nil || run.RealtimeDurationMS < fastest.RealtimeDurationMS {
+			fastest = run
+		}
+	}
+	if fastest == nil {
+		return fmt.Errorf("no completed runs found on splits.io for %s/%s", gameID, categoryID)
+	}
+
+	// The listing may leave out segments, so fetch the full run if needed
+	if len(fastest.Segments) == 0 {
+		var single struct {
+			Run SplitsIORun `json:"run"`
+		}
+		if err := fetchSplitsIO(fmt.Sprintf("%s/runs/%s", splitsIOBaseURL, url.PathEscape(fastest.ID)), &single); err != nil {
+			return err
+		}
+		fastest = &single.Run
+	}
+	if len(fastest.Segments) == 0 {
+		return fmt.Errorf("splits.io run %s has no segments", fastest.ID)
+	}
+
+	speedrun := SpeedrunJSON{
+		Title:        gameID,
+		Category:     categoryID,
+		Attempts:     fastest.Attempts,
+		PersonalBest: &PBData{Attempt: fastest.Attempts},
+	}
+	if fastest.Game != nil && fastest.Game.Name != "" {
+		speedrun.Title = fastest.Game.Name
+	}
+	if fastest.Category != nil && fastest.Category.Name != "" {
+		speedrun.Category = fastest.Category.Name
+	}
+	var end time.Duration
+	for _, segment := range fastest.Segments {
+		speedrun.SplitNames = append(speedrun.SplitNames, segment.Name)
+		// A skipped segment ends where the previous one did, giving it zero time
+		if !segment.RealtimeSkipped {
+			end = time.Duration(segment.RealtimeEndMS) * time.Millisecond
+		}
+		speedrun.PersonalBest.Splits = append(speedrun.PersonalBest.Splits, PBSplit{
+			Time: formatSplitTime(end),
+		})
+	}
+
+	return rm.importSpeedrun(&speedrun)
+}
+
+// fetchSplitsIO GETs a splits.io API URL and decodes the JSON response into v
+func fetchSplitsIO(apiURL string, v interface{}) error {
+	resp, err := splitsIOClient.Get(apiURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch from splits.io: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("splits.io returned %s for %s", resp.Status, apiURL)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse splits.io response: %w", err)
+	}
+	return nil
+}
+
+// splitsIOExchange is a run in the Splits.io Exchange Format
+type splitsIOExchange struct {
+	SchemaVersion string                    `json:"_schemaVersion"`
+	Timer         splitsIOTimer             `json:"timer"`
+	Game          splitsIOName              `json:"game"`
+	Category      splitsIOName              `json:"category"`
+	Attempts      splitsIOAttempts          `json:"attempts"`
+	Segments      []splitsIOExchangeSegment `json:"segments"`
+}
+
+type splitsIOTimer struct {
+	Shortname string `json:"shortname"`
+	Longname  string `json:"longname"`
+	Website   string `json:"website"`
+}
+
+type splitsIOName struct {
+	Longname string `json:"longname"`
+}
+
+type splitsIOAttempts struct {
+	Total     int                      `json:"total"`
+	Histories []splitsIOAttemptHistory `json:"histories"`
+}
+
+type splitsIOAttemptHistory struct {
+	AttemptNumber int    `json:"attemptNumber"`
+	RealtimeMS    *int64 `json:"realtimeMS,omitempty"`
+	StartedAt     string `json:"startedAt,omitempty"`
+	EndedAt       string `json:"endedAt,omitempty"`
+}
+
+type splitsIOExchangeSegment struct {
+	Name         string                   `json:"name"`
+	EndedAt      *splitsIOTime            `json:"endedAt,omitempty"`
+	BestDuration *splitsIOTime            `json:"bestDuration,omitempty"`
+	IsSkipped    bool                     `json:"isSkipped"`
+	Histories    []splitsIOSegmentHistory `json:"histories"`
+}
+
+type splitsIOTime struct {
+	RealtimeMS int64 `json:"realtimeMS"`
+}
+
+type splitsIOSegmentHistory struct {
+	AttemptNumber int   `json:"attemptNumber"`
+	RealtimeMS    int64 `json:"realtimeMS"`
+	IsSkipped     bool  `json:"isSkipped"`
+}
+
+// ExportToSplitsIO writes the whole run history to filepath in the Splits.io
+// Exchange Format: every attempt, the PB's split times, golds and the history
+// of every segment.
+func (rm *RunManager) ExportToSplitsIO(filepath string) error {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	exchange := splitsIOExchange{
+		SchemaVersion: "v1.0.1",
+		Timer: splitsIOTimer{
+			Shortname: "ooosplits",
+			Longname:  "OooSplits",
+			Website:   "https://github.com/nictuku/ooosplits",
+		},
+		Game:     splitsIOName{Longname: rm.title},
+		Category: splitsIOName{Longname: rm.category},
+		Attempts: splitsIOAttempts{Total: rm.attempts},
+		Segments: make([]splitsIOExchangeSegment, rm.numSplits),
+	}
+
+	for i, name := range rm.splitNames {
+		exchange.Segments[i] = splitsIOExchangeSegment{
+			Name:      name,
+			Histories: []splitsIOSegmentHistory{},
+		}
+		if i < len(rm.goldSegments) && rm.goldSegments[i] > 0 {
+			exchange.Segments[i].BestDuration = &splitsIOTime{RealtimeMS: rm.goldSegments[i].Milliseconds()}
+		}
+	}
+	if rm.pb != nil {
+		for i, split := range rm.pb.Splits {
+			if i >= len(exchange.Segments) {
+				break
+			}
+			exchange.Segments[i].EndedAt = &splitsIOTime{RealtimeMS: rm.pb.TimeSince(i).Milliseconds()}
+			exchange.Segments[i].IsSkipped = split.Duration == 0
+		}
+	}
+
+	rows, err := rm.db.Query(`
+		SELECT runs.id, runs.attempt_num, runs.start_time, runs.end_time, runs.completed,
+			splits.split_index, splits.duration_ns
+		FROM runs
+		LEFT JOIN splits ON splits.run_id = runs.id
+		WHERE (? OR runs.archived = 0)
+		ORDER BY runs.id, splits.split_index
+	`, sqlite3Bool(rm.includeArchived))
+	if err != nil {
+		return fmt.Errorf("error loading run history: %w", err)
+	}
+	defer rows.Close()
+
+	var lastRunID int64 = -1
+	var history *splitsIOAttemptHistory
+	var total time.Duration
+	for rows.Next() {
+		var runID int64
+		var attemptNum int
+		var startTime string
+		var endTime sql.NullString
+		var completed bool
+		var splitIndex, durationNs sql.NullInt64
+		if err := rows.Scan(&runID, &attemptNum, &startTime, &endTime, &completed, &splitIndex, &durationNs); err != nil {
+			return fmt.Errorf("error scanning run history: %w", err)
+		}
+
+		if runID != lastRunID {
+			exchange.Attempts.Histories = append(exchange.Attempts.Histories, splitsIOAttemptHistory{
+				AttemptNumber: attemptNum,
+				StartedAt:     startTime,
+			})
+			history = &exchange.Attempts.Histories[len(exchange.Attempts.Histories)-1]
+			history.EndedAt = endTime.String
+			lastRunID = runID
+			total = 0
+		}
+
+		if !splitIndex.Valid || !durationNs.Valid {
+			continue
+		}
+		d := time.Duration(durationNs.Int64)
+		total += d
+		if completed {
+			ms := total.Milliseconds()
+			history.RealtimeMS = &ms
+		}
+		if idx := int(splitIndex.Int64); idx >= 0 && idx < len(exchange.Segments) {
+			segment := &exchange.Segments[idx]
+			segment.Histories = append(segment.Histories, splitsIOSegmentHistory{
+				AttemptNumber: attemptNum,
+				RealtimeMS:    d.Milliseconds(),
+				IsSkipped:     d == 0,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error loading run history: %w", err)
+	}
+
+	data, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode splits.io export: %w", err)
+	}
+	if err := os.WriteFile(filepath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write splits.io export: %w", err)
+	}
+	return nil
+}
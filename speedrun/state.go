@@ -0,0 +1,58 @@
+package speedrun
+
+import "time"
+
+// StreamingState is a snapshot of everything needed to display the timer.
+// All slices are copies, so it stays valid while the run goes on.
+type StreamingState struct {
+	Title      string
+	Category   string
+	Attempts   int
+	SplitNames []SplitNode
+	Splits     []time.Duration
+	// AbsoluteTimes is the run time at the end of each split in Splits
+	AbsoluteTimes []time.Duration
+	PB            *Run
+	Golds         []time.Duration
+	IsRunning     bool
+	IsPaused      bool
+	IsCompleted   bool
+	// CurrentSplit is the index of the active split and CurrentSplitName its
+	// name, or "" when there is none
+	CurrentSplit     int
+	CurrentSplitName string
+	CurrentTime      time.Duration
+	CurrentSplitTime time.Duration
+}
+
+// GetStreamingState returns the display state under a single lock, so
+// readers in other goroutines don't mix fields from before and after a split
+func (rm *RunManager) GetStreamingState() StreamingState {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	name, _ := rm.splitNameByIndex(rm.currentSplit)
+	absoluteTimes := make([]time.Duration, len(rm.splits))
+	var elapsed time.Duration
+	for i, d := range rm.splits {
+		elapsed += d
+		absoluteTimes[i] = elapsed
+	}
+	return StreamingState{
+		Title:            rm.title,
+		Category:         rm.category,
+		Attempts:         rm.attempts,
+		SplitNames:       rm.splitNodes(),
+		Splits:           append([]time.Duration(nil), rm.splits...),
+		AbsoluteTimes:    absoluteTimes,
+		PB:               copyRun(rm.pb),
+		Golds:            append([]time.Duration(nil), rm.goldSegments...),
+		IsRunning:        rm.isRunning,
+		IsPaused:         rm.isPaused(),
+		IsCompleted:      rm.isCompleted,
+		CurrentSplit:     rm.currentSplit,
+		CurrentSplitName: name,
+		CurrentTime:      rm.currentTime(),
+		CurrentSplitTime: rm.currentSplitTime(),
+	}
+}
@@ -0,0 +1,64 @@
+package speedrun
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// SplitStats summarises the segment times of one split over the completed
+// runs. All durations are 0 when SampleCount is 0.
+type SplitStats struct {
+	Mean        time.Duration
+	Median      time.Duration
+	StdDev      time.Duration
+	Min         time.Duration
+	Max         time.Duration
+	SampleCount int
+}
+
+// GetSplitStatistics returns the stats of split splitIndex, or
+// ErrIndexOutOfRange. Like the golds, it leaves out skipped splits and the
+// splits right after them, whose times cover more than one segment.
+func (rm *RunManager) GetSplitStatistics(splitIndex int) (SplitStats, error) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	if splitIndex < 0 || splitIndex >= rm.numSplits {
+		return SplitStats{}, ErrIndexOutOfRange
+	}
+	samples, err := rm.segmentSamples()
+	if err != nil {
+		return SplitStats{}, err
+	}
+	return splitStats(samples[splitIndex]), nil
+}
+
+func splitStats(times []time.Duration) SplitStats {
+	if len(times) == 0 {
+		return SplitStats{}
+	}
+
+	var total time.Duration
+	for _, d := range times {
+		total += d
+	}
+	mean := total / time.Duration(len(times))
+
+	// The population standard deviation, in float64 as the squares overflow
+	// int64 for segments of a few seconds
+	var sumSquares float64
+	for _, d := range times {
+		diff := float64(d - mean)
+		sumSquares += diff * diff
+	}
+	stdDev := math.Sqrt(sumSquares / float64(len(times)))
+
+	return SplitStats{
+		Mean:        mean,
+		Median:      median(times),
+		StdDev:      time.Duration(stdDev),
+		Min:         slices.Min(times),
+		Max:         slices.Max(times),
+		SampleCount: len(times),
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nictuku/ooosplits/speedrun"
+)
+
+const textExportInterval = 250 * time.Millisecond
+
+// runTextExport writes the live timer to path a few times per second so OBS
+// text sources can read it. With withSplit set, the current split name and the
+// last delta against the PB are written on extra lines.
+func runTextExport(rm *speedrun.RunManager, path string, withSplit bool) {
+	ticker := time.NewTicker(textExportInterval)
+	defer ticker.Stop()
+
+	var last string
+	for range ticker.C {
+		content := textExportContent(rm, withSplit)
+		if content == last {
+			continue
+		}
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			log.Printf("Error writing timer text file: %v", err)
+			continue
+		}
+		last = content
+	}
+}
+
+func textExportContent(rm *speedrun.RunManager, withSplit bool) string {
+	state := rm.GetStreamingState()
+	content := formatDurationMicro(state.CurrentTime) + "\n"
+	if !withSplit {
+		return content
+	}
+
+	if state.IsRunning {
+		content += state.CurrentSplitName + "\n"
+	} else {
+		content += "\n"
+	}
+
+	// Delta of the last recorded split against the PB
+	last := len(state.AbsoluteTimes) - 1
+	pb := state.PB
+	if last >= 0 && pb != nil && last < len(pb.Splits) {
+		diff := state.AbsoluteTimes[last] - pb.TimeSince(last)
+		if diff < 0 {
+			content += fmt.Sprintf("-%s\n", formatDuration(-diff))
+		} else {
+			content += fmt.Sprintf("+%s\n", formatDuration(diff))
+		}
+	}
+	return content
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into
+// place, so readers never see a half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
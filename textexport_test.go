@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nictuku/ooosplits/speedrun"
+)
+
+// newTestRunManager opens a RunManager on a new database in a temp dir, with
+// a PB of 1:00 and 2:00 for splits A and B
+func newTestRunManager(t *testing.T) *speedrun.RunManager {
+	t.Helper()
+	dir := t.TempDir()
+	splitsPath := filepath.Join(dir, "splits.json")
+	splits := `{
+		"title": "Test", "category": "Any%", "attempts": 1, "completed": 1,
+		"split_names": ["A", "B"],
+		"personal_best": {"attempt": 1, "splits": [{"time": "1:00"}, {"time": "2:00"}]}
+	}`
+	if err := os.WriteFile(splitsPath, []byte(splits), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rm, err := speedrun.NewRunManager(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewRunManager: %v", err)
+	}
+	t.Cleanup(func() { rm.Close() })
+	if err := rm.ImportFromJSON(splitsPath); err != nil {
+		t.Fatalf("ImportFromJSON: %v", err)
+	}
+	return rm
+}
+
+func TestTextExportContent(t *testing.T) {
+	rm := newTestRunManager(t)
+	if got, want := textExportContent(rm, false), "00:00.00\n"; got != want {
+		t.Errorf("before the run: %q, want %q", got, want)
+	}
+	if got, want := textExportContent(rm, true), "00:00.00\n\n"; got != want {
+		t.Errorf("before the run, with split: %q, want %q", got, want)
+	}
+
+	rm.StartRun()
+	lines := strings.Split(textExportContent(rm, true), "\n")
+	if len(lines) != 3 || lines[1] != "A" {
+		t.Errorf("on the first split: %q, want the time, A and no delta", lines)
+	}
+
+	// The split lands well ahead of the PB's 1:00
+	if _, err := rm.Split(); err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	lines = strings.Split(textExportContent(rm, true), "\n")
+	if len(lines) != 4 || lines[1] != "B" || !strings.HasPrefix(lines[2], "-59.") {
+		t.Errorf("after the first split: %q, want the time, B and a -59s delta", lines)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "timer.txt")
+	for _, content := range []string{"00:01.00\n", "00:02.00\n"} {
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			t.Fatalf("writeFileAtomic: %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("file holds %q, want %q", got, content)
+		}
+	}
+
+	// No temp files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files in the directory, want only timer.txt", len(entries))
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "timer.txt"), nil); err == nil {
+		t.Error("writing into a missing directory succeeded")
+	}
+}